@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"go-graphql-api/util"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Returned, possibly wrapped, for every token that can not be accepted:
+// malformed, badly signed, expired, revoked...
+var ErrInvalidToken = errors.New("invalid token")
+
+// Returned for tokens past their expiry, wraps `ErrInvalidToken`.
+var ErrExpiredToken = fmt.Errorf("%w: token has expired", ErrInvalidToken)
+
+var (
+	_issuer   = util.EnvOrDefault("JWT_ISSUER", util.ServerUri())
+	_audience = util.EnvOrDefault("JWT_AUDIENCE", util.ServerUri())
+	// Tolerance for the clocks of the servers being out of sync when
+	// checking the time based claims.
+	_clock_skew = time.Duration(util.EnvIntOrDefault("JWT_CLOCK_SKEW_SECONDS", 30)) * time.Second
+)
+
+func invalid_token(format string, a ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, fmt.Sprintf(format, a...))
+}
+
+// Check the registered claims of an access token: it must have an
+// expiry, be valid at `now`, and be issued by JWT_ISSUER for JWT_AUDIENCE.
+func validate_claims(claims jwt.MapClaims, now time.Time) error {
+	expiry, ok := time_claim(claims, "exp")
+	if !ok {
+		return invalid_token("missing exp claim")
+	}
+	if now.After(expiry.Add(_clock_skew)) {
+		return ErrExpiredToken
+	}
+	if not_before, ok := time_claim(claims, "nbf"); ok && now.Add(_clock_skew).Before(not_before) {
+		return invalid_token("token is not valid yet")
+	}
+	if issued_at, ok := time_claim(claims, "iat"); ok && now.Add(_clock_skew).Before(issued_at) {
+		return invalid_token("token was issued in the future")
+	}
+
+	if issuer, _ := claims["iss"].(string); issuer != _issuer {
+		return invalid_token("unexpected issuer")
+	}
+	if !has_audience(claims, _audience) {
+		return invalid_token("unexpected audience")
+	}
+	return nil
+}
+
+func time_claim(claims jwt.MapClaims, name string) (time.Time, bool) {
+	value, ok := claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
+
+// The `aud` claim is either a single audience or a list of them.
+func has_audience(claims jwt.MapClaims, audience string) bool {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"go-graphql-api/util"
+	"net/http"
+	"time"
+)
+
+// The refresh token cookie is only sent to the `/auth` endpoints.
+const _refresh_cookie_path = "/auth"
+
+var (
+	_session_cookie_name   = util.EnvOrDefault("AUTH_COOKIE_NAME", "auth_token")
+	_refresh_cookie_name   = util.EnvOrDefault("REFRESH_COOKIE_NAME", "refresh_token")
+	_session_cookie_secure = util.EnvBoolOrDefault("AUTH_COOKIE_SECURE", true)
+)
+
+// Whether cookies are restricted to HTTPS, from AUTH_COOKIE_SECURE.
+func SecureCookies() bool {
+	return _session_cookie_secure
+}
+
+// Store the tokens of `session` in HttpOnly cookies, so browsers send
+// them along with their requests without scripts being able to read them.
+func SetSessionCookies(w http.ResponseWriter, session *Session) {
+	set_cookie(w, _session_cookie_name, "/", session.AccessToken, session.AccessTokenExpiry)
+	set_cookie(w, _refresh_cookie_name, _refresh_cookie_path, session.RefreshToken, session.RefreshTokenExpiry)
+}
+
+// Remove the cookies set by `SetSessionCookies`.
+func ClearSessionCookies(w http.ResponseWriter) {
+	set_cookie(w, _session_cookie_name, "/", "", time.Unix(0, 0))
+	set_cookie(w, _refresh_cookie_name, _refresh_cookie_path, "", time.Unix(0, 0))
+}
+
+func set_cookie(w http.ResponseWriter, name string, path string, value string, expiry time.Time) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   _session_cookie_secure,
+		// Lax so the cookies are set when coming back from the provider.
+		SameSite: http.SameSiteLaxMode,
+	}
+	if len(value) == 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// Get the access token stored by `SetSessionCookies`, or an empty string
+// when the request has none.
+func SessionCookieToken(r *http.Request) string {
+	return cookie_value(r, _session_cookie_name)
+}
+
+// Same as `SessionCookieToken`, for the refresh token.
+func RefreshCookieToken(r *http.Request) string {
+	return cookie_value(r, _refresh_cookie_name)
+}
+
+func cookie_value(r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
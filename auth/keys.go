@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Key used to sign or verify access tokens, identified in the tokens by
+// the `kid` header.
+type signing_key struct {
+	id     string
+	method jwt.SigningMethod
+	// Nil for keys that only verify tokens.
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+	// Tokens signed with the key are rejected past this time.
+	expires_at *time.Time
+}
+
+func (k *signing_key) expired(now time.Time) bool {
+	return k.expires_at != nil && now.After(*k.expires_at)
+}
+
+// Keys of the server, loaded from the JWT_KEYSET manifest.
+type keyset struct {
+	signing *signing_key
+	keys    map[string]*signing_key
+}
+
+// Nil until `LoadKeySet` loads a keyset, in which case tokens are signed
+// with HMAC using JWT_SECRET.
+var _keyset *keyset
+
+// Format of the JWT_KEYSET manifest. Paths of the PEM files are relative
+// to the manifest.
+//
+//	{
+//	  "signing_key": "2024-06",
+//	  "keys": [
+//	    {"kid": "2024-06", "file": "2024-06.pem"},
+//	    {"kid": "2024-01", "file": "2024-01.pem", "expires_at": "2024-07-01T00:00:00Z"}
+//	  ]
+//	}
+type keyset_manifest struct {
+	SigningKey string `json:"signing_key"`
+	Keys       []struct {
+		ID        string     `json:"kid"`
+		File      string     `json:"file"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	} `json:"keys"`
+}
+
+// Load the RS256/ES256 keys listed in the manifest at JWT_KEYSET. When
+// the variable is not set, tokens keep being signed with JWT_SECRET.
+func LoadKeySet() error {
+	manifest_path := util.EnvOrDefault("JWT_KEYSET", "")
+	if len(manifest_path) == 0 {
+		logger.Warn("JWT_KEYSET not defined, access tokens are signed with JWT_SECRET")
+		return nil
+	}
+
+	content, err := os.ReadFile(manifest_path)
+	if err != nil {
+		return fmt.Errorf("failed to read jwt keyset: %v", err)
+	}
+	var manifest keyset_manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return fmt.Errorf("failed to parse jwt keyset: %v", err)
+	}
+
+	set := keyset{keys: map[string]*signing_key{}}
+	for _, entry := range manifest.Keys {
+		if len(entry.ID) == 0 {
+			return fmt.Errorf("jwt keyset entry for %s has no kid", entry.File)
+		}
+		if _, ok := set.keys[entry.ID]; ok {
+			return fmt.Errorf("duplicate kid %q in jwt keyset", entry.ID)
+		}
+		pem, err := os.ReadFile(filepath.Join(filepath.Dir(manifest_path), entry.File))
+		if err != nil {
+			return fmt.Errorf("failed to read key %q: %v", entry.ID, err)
+		}
+		key, err := parse_pem_key(pem)
+		if err != nil {
+			return fmt.Errorf("failed to parse key %q: %v", entry.ID, err)
+		}
+		key.id = entry.ID
+		key.expires_at = entry.ExpiresAt
+		set.keys[key.id] = key
+	}
+
+	set.signing = set.keys[manifest.SigningKey]
+	switch {
+	case set.signing == nil:
+		return fmt.Errorf("signing key %q not found in jwt keyset", manifest.SigningKey)
+	case set.signing.private == nil:
+		return fmt.Errorf("signing key %q has no private key", manifest.SigningKey)
+	case set.signing.expired(time.Now()):
+		return fmt.Errorf("signing key %q has expired", manifest.SigningKey)
+	}
+
+	_keyset = &set
+	logger.Info("Loaded %d jwt key(s), signing with %q", len(set.keys), set.signing.id)
+	return nil
+}
+
+// Parse a PEM encoded RSA or P-256 key, either a private key or, for keys
+// that only verify tokens, a public key.
+func parse_pem_key(pem []byte) (*signing_key, error) {
+	if private, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return &signing_key{method: jwt.SigningMethodRS256, private: private, public: &private.PublicKey}, nil
+	}
+	if public, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+		return &signing_key{method: jwt.SigningMethodRS256, public: public}, nil
+	}
+
+	var public *ecdsa.PublicKey
+	private, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err == nil {
+		public = &private.PublicKey
+	} else if public, err = jwt.ParseECPublicKeyFromPEM(pem); err != nil {
+		return nil, fmt.Errorf("not a PEM encoded RSA or EC key")
+	}
+	if public.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("EC keys must use the P-256 curve")
+	}
+	key := &signing_key{method: jwt.SigningMethodES256, public: public}
+	if private != nil {
+		key.private = private
+	}
+	return key, nil
+}
+
+// Sign a token with the given `claims`, using the signing key of the
+// keyset, or JWT_SECRET when there is no keyset.
+func sign_token(claims jwt.MapClaims) (string, error) {
+	if _keyset == nil {
+		secret, err := signing_secret()
+		if err != nil {
+			return "", err
+		}
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	}
+
+	key := _keyset.signing
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
+}
+
+// Find the key to verify `token` with. With a keyset, the key is picked
+// from the `kid` header, and must match the algorithm of the token.
+func verification_key(token *jwt.Token) (interface{}, error) {
+	if _keyset == nil {
+		// Must validate that the token is using the expected algo.
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return signing_secret()
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := _keyset.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if key.expired(time.Now()) {
+		return nil, fmt.Errorf("signing key %q has expired", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+// Public keys that currently verify tokens, in the JSON Web Key format.
+func public_jwks() []map[string]interface{} {
+	keys := []map[string]interface{}{}
+	if _keyset == nil {
+		return keys
+	}
+
+	ids := make([]string, 0, len(_keyset.keys))
+	for id := range _keyset.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	now := time.Now()
+	for _, id := range ids {
+		key := _keyset.keys[id]
+		if key.expired(now) {
+			continue
+		}
+		jwk := map[string]interface{}{
+			"kid": key.id,
+			"alg": key.method.Alg(),
+			"use": "sig",
+		}
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			jwk["kty"] = "EC"
+			jwk["crv"] = "P-256"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(public.X.FillBytes(make([]byte, 32)))
+			jwk["y"] = base64.RawURLEncoding.EncodeToString(public.Y.FillBytes(make([]byte, 32)))
+		}
+		keys = append(keys, jwk)
+	}
+	return keys
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/jobs"
+	"go-graphql-api/util"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/jinzhu/gorm"
+)
+
+// Revoked access tokens, kept in memory so checking a token does not hit
+// the database, and persisted in MySQL so revocations survive restarts.
+// Every replica reloads the revocations periodically to pick up the ones
+// made by the other replicas.
+type revocation_store struct {
+	db *gorm.DB
+
+	mu sync.RWMutex
+	// Expiry of the revoked tokens, by token id.
+	tokens map[string]time.Time
+	// Expiry of the last access token of the revoked sessions, by session
+	// id.
+	sessions map[string]time.Time
+	// Tokens issued up to the cutoff are revoked, by user id.
+	user_cutoffs map[uint64]time.Time
+}
+
+// Nil until `StartRevocationStore` is called, in which case no token is
+// considered revoked.
+var _revocations *revocation_store
+
+// Load the revoked tokens from `db`, and keep them in sync every
+// REVOCATION_SYNC_SECONDS.
+func StartRevocationStore(db *gorm.DB) error {
+	store := &revocation_store{db: db}
+	if err := store.load(); err != nil {
+		return err
+	}
+	_revocations = store
+
+	interval := time.Duration(util.EnvIntOrDefault("REVOCATION_SYNC_SECONDS", 30)) * time.Second
+	jobs.RunPeriodically("token-revocation-sync", interval, func() error {
+		if err := store.purge_expired(); err != nil {
+			return err
+		}
+		return store.load()
+	})
+	return nil
+}
+
+func (s *revocation_store) load() error {
+	var revoked_tokens []dbmodel.RevokedToken
+	if err := s.db.Where("expires_at > ?", time.Now()).Find(&revoked_tokens).Error; err != nil {
+		return fmt.Errorf("failed to load revoked tokens: %v", err)
+	}
+	var revoked_sessions []dbmodel.RevokedSession
+	if err := s.db.Where("expires_at > ?", time.Now()).Find(&revoked_sessions).Error; err != nil {
+		return fmt.Errorf("failed to load revoked sessions: %v", err)
+	}
+	var user_revocations []dbmodel.UserTokenRevocation
+	if err := s.db.Find(&user_revocations).Error; err != nil {
+		return fmt.Errorf("failed to load user token revocations: %v", err)
+	}
+
+	tokens := make(map[string]time.Time, len(revoked_tokens))
+	for _, token := range revoked_tokens {
+		tokens[token.TokenID] = token.ExpiresAt
+	}
+	sessions := make(map[string]time.Time, len(revoked_sessions))
+	for _, session := range revoked_sessions {
+		sessions[session.SessionID] = session.ExpiresAt
+	}
+	user_cutoffs := make(map[uint64]time.Time, len(user_revocations))
+	for _, revocation := range user_revocations {
+		user_cutoffs[revocation.UserID] = revocation.RevokedBefore
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Keep the revocations made since the query ran.
+	for id, expiry := range s.tokens {
+		if _, ok := tokens[id]; !ok && expiry.After(time.Now()) {
+			tokens[id] = expiry
+		}
+	}
+	for id, expiry := range s.sessions {
+		if _, ok := sessions[id]; !ok && expiry.After(time.Now()) {
+			sessions[id] = expiry
+		}
+	}
+	oldest_cutoff := time.Now().Add(-AccessTokenTTL())
+	for user_id, cutoff := range s.user_cutoffs {
+		if cutoff.After(user_cutoffs[user_id]) && cutoff.After(oldest_cutoff) {
+			user_cutoffs[user_id] = cutoff
+		}
+	}
+	s.tokens = tokens
+	s.sessions = sessions
+	s.user_cutoffs = user_cutoffs
+	return nil
+}
+
+// Drop the revocations of tokens that expired anyway.
+func (s *revocation_store) purge_expired() error {
+	now := time.Now()
+	if err := s.db.Where("expires_at <= ?", now).Delete(&dbmodel.RevokedToken{}).Error; err != nil {
+		return fmt.Errorf("failed to purge revoked tokens: %v", err)
+	}
+	if err := s.db.Where("expires_at <= ?", now).Delete(&dbmodel.RevokedSession{}).Error; err != nil {
+		return fmt.Errorf("failed to purge revoked sessions: %v", err)
+	}
+	// Every token issued before the cutoff has expired past the lifetime
+	// of access tokens.
+	err := s.db.Where("revoked_before < ?", now.Add(-AccessTokenTTL())).
+		Delete(&dbmodel.UserTokenRevocation{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to purge user token revocations: %v", err)
+	}
+	return nil
+}
+
+func (s *revocation_store) is_revoked(token_id string, session_id string, user_id uint64, issued_at time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.tokens[token_id]; ok {
+		return true
+	}
+	if _, ok := s.sessions[session_id]; ok && len(session_id) > 0 {
+		return true
+	}
+	cutoff, ok := s.user_cutoffs[user_id]
+	return ok && issued_at.Before(cutoff)
+}
+
+func (s *revocation_store) revoke_token(token_id string, user_id uint64, expiry time.Time) error {
+	err := s.db.Exec(
+		"INSERT IGNORE INTO revoked_tokens (token_id, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)",
+		token_id, user_id, expiry, time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %v", err)
+	}
+
+	s.mu.Lock()
+	s.tokens[token_id] = expiry
+	s.mu.Unlock()
+	return nil
+}
+
+// Revoke the access tokens of the session `session_id`. They are all
+// expired once the lifetime of access tokens passed.
+func (s *revocation_store) revoke_session(session_id string, user_id uint64) error {
+	expiry := time.Now().Add(AccessTokenTTL())
+	err := s.db.Exec(
+		"INSERT INTO revoked_sessions (session_id, user_id, expires_at, created_at) VALUES (?, ?, ?, ?) "+
+			"ON DUPLICATE KEY UPDATE expires_at = VALUES(expires_at)",
+		session_id, user_id, expiry, time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+
+	s.mu.Lock()
+	s.sessions[session_id] = expiry
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *revocation_store) revoke_user_tokens(user_id uint64) error {
+	// Stored with a millisecond precision, like the `iat` claim.
+	cutoff := time.Now().Truncate(time.Millisecond)
+	err := s.db.Exec(
+		"INSERT INTO user_token_revocations (user_id, revoked_before) VALUES (?, ?) "+
+			"ON DUPLICATE KEY UPDATE revoked_before = VALUES(revoked_before)",
+		user_id, cutoff).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke tokens of user %d: %v", user_id, err)
+	}
+
+	s.mu.Lock()
+	s.user_cutoffs[user_id] = cutoff
+	s.mu.Unlock()
+	return nil
+}
+
+// Check whether the access token with the given `claims` was revoked.
+func TokenRevoked(claims jwt.MapClaims) bool {
+	if _revocations == nil {
+		return false
+	}
+	token_id, _ := claims[Claim_TokenID].(string)
+	session_id, _ := claims[Claim_SessionID].(string)
+	user_id, _ := claims[Claim_UserID].(float64)
+	issued_at, _ := claims["iat"].(float64)
+	return _revocations.is_revoked(token_id, session_id, uint64(user_id), time.UnixMilli(int64(math.Round(issued_at*1000))))
+}
+
+// Revoke the access token with the given `claims`, along with the other
+// access tokens and the refresh tokens of its session.
+func RevokeSession(claims jwt.MapClaims) error {
+	if _revocations == nil {
+		return fmt.Errorf("token revocation is not available")
+	}
+	token_id, _ := claims[Claim_TokenID].(string)
+	user_id, _ := claims[Claim_UserID].(float64)
+	expiry, _ := claims["exp"].(float64)
+	if len(token_id) > 0 {
+		err := _revocations.revoke_token(token_id, uint64(user_id), time.Unix(int64(expiry), 0))
+		if err != nil {
+			return err
+		}
+	}
+	if session_id, ok := claims[Claim_SessionID].(string); ok && len(session_id) > 0 {
+		if err := _revocations.revoke_session(session_id, uint64(user_id)); err != nil {
+			return err
+		}
+		return revoke_family(_revocations.db, session_id)
+	}
+	return nil
+}
+
+// Revoke the access tokens issued for the refresh token family
+// `session_id`, once its refresh tokens are revoked.
+func revoke_session_access_tokens(session_id string, user_id uint64) error {
+	if _revocations == nil {
+		return fmt.Errorf("token revocation is not available")
+	}
+	return _revocations.revoke_session(session_id, user_id)
+}
+
+// Revoke every access and refresh token issued to the user `user_id`
+// so far, signing them out of all their sessions.
+func RevokeUserTokens(user_id uint64) error {
+	if _revocations == nil {
+		return fmt.Errorf("token revocation is not available")
+	}
+	if err := _revocations.revoke_user_tokens(user_id); err != nil {
+		return err
+	}
+	err := _revocations.db.Model(&dbmodel.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", user_id).
+		UpdateColumn("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens of user %d: %v", user_id, err)
+	}
+	return nil
+}
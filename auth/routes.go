@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/jinzhu/gorm"
+)
+
+func RegisterAuthRoutes(router *chi.Mux, db *gorm.DB) {
+	logger.Info("Registering auth route handlers: /auth/refresh, /auth/logout, /.well-known/jwks.json")
+	router.Post("/auth/refresh", refresh_handler(db))
+	router.Post("/auth/logout", logout_handler(db))
+	router.Get("/.well-known/jwks.json", jwks_handler)
+}
+
+// Publish the public keys verifying the access tokens, so other services
+// can verify them too.
+func jwks_handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": public_jwks(),
+	})
+}
+
+// Rotate the refresh token of a session and issue a new access token.
+//
+// Clients holding their tokens send `{"refresh_token": "..."}` and get
+// the new tokens back in the response. Browsers using the session
+// cookies send no body, and get their cookies replaced instead.
+func refresh_handler(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			RefreshToken string `json:"refresh_token"`
+		}
+		// The length of the body is unknown for chunked requests, an empty
+		// body is only noticed when decoding it.
+		err := json.NewDecoder(r.Body).Decode(&body)
+		if err != nil && !errors.Is(err, io.EOF) {
+			util.WriteJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error": "Invalid request body",
+			})
+			return
+		}
+		from_cookie := len(body.RefreshToken) == 0
+		if from_cookie {
+			body.RefreshToken = RefreshCookieToken(r)
+		}
+
+		session, err := RefreshSession(db, body.RefreshToken)
+		if errors.Is(err, ErrInvalidRefreshToken) {
+			if from_cookie {
+				ClearSessionCookies(w)
+			}
+			util.WriteJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"error": "Invalid refresh token",
+			})
+			return
+		}
+		if err != nil {
+			logger.Err("Failed to refresh session: %v", err)
+			util.WriteJSON(w, http.StatusInternalServerError, map[string]interface{}{
+				"error": "Internal error",
+			})
+			return
+		}
+
+		if from_cookie {
+			SetSessionCookies(w, session)
+			util.WriteJSON(w, http.StatusOK, map[string]interface{}{
+				"expires_in": util.SecondsUntil(session.AccessTokenExpiry),
+			})
+			return
+		}
+		util.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"access_token":       session.AccessToken,
+			"token_type":         "Bearer",
+			"expires_in":         util.SecondsUntil(session.AccessTokenExpiry),
+			"refresh_token":      session.RefreshToken,
+			"refresh_expires_in": util.SecondsUntil(session.RefreshTokenExpiry),
+		})
+	}
+}
+
+// Sign out the browser session of the request: revoke the tokens of its
+// session cookies, then clear them.
+func logout_handler(db *gorm.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if claims, err := ParseAccessToken(SessionCookieToken(r)); err == nil {
+			if err := RevokeSession(claims); err != nil {
+				logger.Err("Failed to revoke session: %v", err)
+			}
+		}
+		// The access token may have expired already.
+		if refresh_token := RefreshCookieToken(r); len(refresh_token) > 0 {
+			if err := revoke_refresh_token_family(db, refresh_token); err != nil {
+				logger.Err("Failed to revoke session: %v", err)
+			}
+		}
+		ClearSessionCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
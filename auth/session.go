@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var ErrInvalidRefreshToken = errors.New("invalid refresh token")
+
+// How long a refresh token can be exchanged for a new access token.
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(util.EnvIntOrDefault("REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour
+}
+
+// Credentials of a first-party session.
+type Session struct {
+	AccessToken        string
+	AccessTokenExpiry  time.Time
+	RefreshToken       string
+	RefreshTokenExpiry time.Time
+}
+
+// Start a new session for `user`, with a new refresh token family.
+func StartSession(db *gorm.DB, user *dbmodel.User) (*Session, error) {
+	family, err := random_token()
+	if err != nil {
+		return nil, err
+	}
+	return issue_session(db, user, family)
+}
+
+// Exchange `refresh_token` for the credentials of a new session of the
+// same family. A refresh token can only be exchanged once: presenting it
+// again revokes every token of its family, as either the client or an
+// attacker holds a stolen copy.
+func RefreshSession(db *gorm.DB, refresh_token string) (*Session, error) {
+	var session *Session
+	var reused *dbmodel.RefreshToken
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var record dbmodel.RefreshToken
+		err := tx.Set("gorm:query_option", "FOR UPDATE").
+			Where("token_hash = ?", hash_token(refresh_token)).
+			First(&record).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch refresh token: %v", err)
+		}
+
+		now := time.Now()
+		switch {
+		case record.RevokedAt != nil || now.After(record.ExpiresAt):
+			return ErrInvalidRefreshToken
+		case record.UsedAt != nil:
+			reused = &record
+			return revoke_family(tx, record.FamilyID)
+		}
+
+		var user dbmodel.User
+		err = tx.First(&user, record.UserID).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch user %d: %v", record.UserID, err)
+		}
+
+		if err := tx.Model(&record).UpdateColumn("used_at", now).Error; err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %v", err)
+		}
+		session, err = issue_session(tx, &user, record.FamilyID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused != nil {
+		logger.Warn("Refresh token %d of user %d was reused, revoked its token family", reused.ID, reused.UserID)
+		// The access tokens already issued for the family may be in the
+		// wrong hands as well.
+		if err := revoke_session_access_tokens(reused.FamilyID, reused.UserID); err != nil {
+			logger.Err("Failed to revoke access tokens of reused refresh token %d: %v", reused.ID, err)
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	return session, nil
+}
+
+func issue_session(db *gorm.DB, user *dbmodel.User, family string) (*Session, error) {
+	refresh_token, err := random_token()
+	if err != nil {
+		return nil, err
+	}
+	record := dbmodel.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  family,
+		TokenHash: hash_token(refresh_token),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
+	}
+	if err := db.Create(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	access_token, access_token_expiry, err := IssueAccessToken(user, family)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		AccessToken:        access_token,
+		AccessTokenExpiry:  access_token_expiry,
+		RefreshToken:       refresh_token,
+		RefreshTokenExpiry: record.ExpiresAt,
+	}, nil
+}
+
+func revoke_family(db *gorm.DB, family string) error {
+	err := db.Model(&dbmodel.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", family).
+		UpdateColumn("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %v", err)
+	}
+	return nil
+}
+
+// Revoke the family of `refresh_token`, if it is a known refresh token.
+func revoke_refresh_token_family(db *gorm.DB, refresh_token string) error {
+	var record dbmodel.RefreshToken
+	err := db.Where("token_hash = ?", hash_token(refresh_token)).First(&record).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch refresh token: %v", err)
+	}
+	return revoke_family(db, record.FamilyID)
+}
+
+// 256 random bits, URL safe.
+func random_token() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hash_token(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	// Claim holding the id of the user a token was issued to.
+	Claim_UserID = "id"
+	// Claim holding the unique id of a token, used to revoke it.
+	Claim_TokenID = "jti"
+	// Claim holding the refresh token family of the session a token was
+	// issued for.
+	Claim_SessionID = "sid"
+)
+
+// How long the access tokens issued by the server are valid.
+func AccessTokenTTL() time.Duration {
+	return time.Duration(util.EnvIntOrDefault("JWT_ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute
+}
+
+func signing_secret() ([]byte, error) {
+	secret := util.EnvOrDefault("JWT_SECRET", "")
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("No jwt secret found.")
+	}
+	return []byte(secret), nil
+}
+
+// Sign an access token identifying `user` in the session `session_id`,
+// accepted by `gql_middleware.JwtAuthMiddleware`. Returns the token and
+// its expiry.
+func IssueAccessToken(user *dbmodel.User, session_id string) (string, time.Time, error) {
+	token_id, err := random_token()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiry := now.Add(AccessTokenTTL())
+	// In milliseconds, so the revocations of the tokens of a user can tell
+	// apart the tokens issued in the same second.
+	issued_at := float64(now.UnixMilli()) / 1000
+	signed, err := sign_token(jwt.MapClaims{
+		Claim_UserID:    user.ID,
+		Claim_SessionID: session_id,
+		Claim_TokenID:   token_id,
+		"iss":           _issuer,
+		"aud":           _audience,
+		"iat":           issued_at,
+		"nbf":           now.Unix(),
+		"exp":           expiry.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign access token: %v", err)
+	}
+	return signed, expiry, nil
+}
+
+// Verify the signature and the claims of an access token, and get its
+// claims. Tokens that are not accepted, including revoked ones, fail
+// with an error wrapping `ErrInvalidToken`.
+func ParseAccessToken(tokenstr string) (jwt.MapClaims, error) {
+	// The claims are validated below, with the clock skew and the
+	// required issuer and audience.
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(tokenstr, verification_key)
+	if err != nil {
+		return nil, invalid_token("%v", err)
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, invalid_token("Failed to get claims from jwt auth token")
+	}
+	if err := validate_claims(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	if TokenRevoked(claims) {
+		return nil, invalid_token("token has been revoked")
+	}
+	return claims, nil
+}
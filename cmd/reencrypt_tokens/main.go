@@ -0,0 +1,90 @@
+// Re-encrypt the provider tokens stored in the database with the active
+// key of TOKEN_ENCRYPTION_KEYS. Run it after rotating the key, or after
+// enabling encryption to encrypt the tokens stored in plaintext, then
+// drop the old key once it is done. Tokens encrypted in an older format
+// are encrypted again as well.
+//
+//	go run ./cmd/reencrypt_tokens
+package main
+
+import (
+	"fmt"
+	"go-graphql-api/database"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/envelope"
+	"go-graphql-api/util/logger"
+	"os"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+func main() {
+	if err := run(); err != nil {
+		logger.Err("Failed to re-encrypt oauth tokens: %v", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	if err := envelope.LoadKeys(); err != nil {
+		return fmt.Errorf("failed to load token encryption keys: %v", err)
+	}
+	if !envelope.Enabled() {
+		return fmt.Errorf("TOKEN_ENCRYPTION_KEYS must be set")
+	}
+	db, err := database.GetDbInstance()
+	if err != nil {
+		return fmt.Errorf("failed to instantiate database connection: %v", err)
+	}
+
+	var ids []uint64
+	err = db.Model(&dbmodel.OAuthToken{}).
+		Where("key_id <> ? OR data_key NOT LIKE ?", envelope.ActiveKeyID(), dbmodel.DataKeyFormat_Owner+"%").
+		Order("id").
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Re-encrypting %d oauth token(s) with key %q", len(ids), envelope.ActiveKeyID())
+	reencrypted := 0
+	for _, id := range ids {
+		done, err := reencrypt_token(db, id)
+		if err != nil {
+			return fmt.Errorf("oauth token %d: %v", id, err)
+		}
+		if done {
+			reencrypted++
+		}
+	}
+	logger.Info("Re-encrypted %d oauth token(s)", reencrypted)
+	return nil
+}
+
+// Decrypt the token `id` and save it back, which encrypts it with the
+// active key in the current format. The row is locked so a login
+// replacing it at the same time is not undone. Returns false when the
+// token was deleted or already re-encrypted meanwhile.
+func reencrypt_token(db *gorm.DB, id uint64) (bool, error) {
+	done := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var token dbmodel.OAuthToken
+		err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&token).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if token.KeyID == envelope.ActiveKeyID() && strings.HasPrefix(token.DataKey, dbmodel.DataKeyFormat_Owner) {
+			return nil
+		}
+		if err := tx.Save(&token).Error; err != nil {
+			return err
+		}
+		done = true
+		return nil
+	})
+	return done, err
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/logger"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// A data migration that runs once, after the models are auto migrated.
+// Use these for changes `AutoMigrate` can not do on its own, like moving
+// data between columns or dropping columns.
+type migration struct {
+	id  string
+	run func(db *gorm.DB) error
+}
+
+// Migrations are applied in order and each one is recorded in the
+// `schema_migrations` table so it is never applied twice. Never reorder
+// or remove entries from this list; only append.
+var _migrations = []migration{
+	{"0001_post_author_to_user", migrate_post_author_to_user},
+	{"0002_post_timestamps_to_datetime", migrate_post_timestamps_to_datetime},
+	{"0003_publish_existing_posts", migrate_publish_existing_posts},
+	{"0004_oauth_tokens_to_text", migrate_oauth_tokens_to_text},
+	{"0005_token_revocation_milliseconds", migrate_token_revocation_milliseconds},
+}
+
+func run_data_migrations(db *gorm.DB) error {
+	for _, m := range _migrations {
+		var applied dbmodel.SchemaMigration
+		err := db.Where("id = ?", m.id).First(&applied).Error
+		if err == nil {
+			continue
+		}
+		if !gorm.IsRecordNotFoundError(err) {
+			return err
+		}
+
+		logger.Info("> Applying data migration: %s", m.id)
+		if err := m.run(db); err != nil {
+			return fmt.Errorf("data migration %s failed: %v", m.id, err)
+		}
+		err = db.Create(&dbmodel.SchemaMigration{ID: m.id, AppliedAt: time.Now()}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Posts used to store their author as a free-text `author` column. Link
+// every post to a user account instead, matching the old value against
+// the user emails. Authors without an account get a user created for
+// them so no post is left without an author.
+func migrate_post_author_to_user(db *gorm.DB) error {
+	posts_table := db.NewScope(&dbmodel.Post{}).TableName()
+	users_table := db.NewScope(&dbmodel.User{}).TableName()
+
+	if db.Dialect().HasColumn(posts_table, "author") {
+		var authors []string
+		err := db.Table(posts_table).Where("author_id = 0").Pluck("DISTINCT author", &authors).Error
+		if err != nil {
+			return err
+		}
+
+		for _, author := range authors {
+			var user dbmodel.User
+			err := db.Where("email = ?", author).First(&user).Error
+			if gorm.IsRecordNotFoundError(err) {
+				logger.Info("Creating user for legacy post author %q", author)
+				user = dbmodel.User{Email: author, Type: dbmodel.UserType_Normal}
+				err = db.Create(&user).Error
+			}
+			if err != nil {
+				return err
+			}
+
+			err = db.Table(posts_table).
+				Where("author = ? AND author_id = 0", author).
+				UpdateColumn("author_id", user.ID).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := db.Model(&dbmodel.Post{}).DropColumn("author").Error; err != nil {
+			return err
+		}
+	}
+
+	return db.Model(&dbmodel.Post{}).
+		AddForeignKey("author_id", users_table+"(id)", "RESTRICT", "RESTRICT").Error
+}
+
+// Get the SQL data type of `column` in `table`, e.g. "varchar".
+func column_data_type(db *gorm.DB, table string, column string) (string, error) {
+	var data_type string
+	row := db.Raw(`SELECT DATA_TYPE FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?`, table, column).Row()
+	if err := row.Scan(&data_type); err != nil {
+		return "", err
+	}
+	return strings.ToLower(data_type), nil
+}
+
+// Post timestamps used to be free-form strings set by the clients.
+// Convert the `published_at` and `updated_at` columns to DATETIME, keeping
+// every value that is a valid RFC 3339 timestamp, and fill in the new
+// `created_at` column for the existing posts.
+func migrate_post_timestamps_to_datetime(db *gorm.DB) error {
+	posts_table := db.NewScope(&dbmodel.Post{}).TableName()
+	const mysql_datetime = "2006-01-02 15:04:05"
+
+	for _, column := range []string{"published_at", "updated_at"} {
+		data_type, err := column_data_type(db, posts_table, column)
+		if err != nil {
+			return err
+		}
+		if data_type == "datetime" {
+			continue
+		}
+
+		rows, err := db.Table(posts_table).Unscoped().Select("id, " + column).Rows()
+		if err != nil {
+			return err
+		}
+		converted := map[uint64]*string{}
+		for rows.Next() {
+			var id uint64
+			var value sql.NullString
+			if err := rows.Scan(&id, &value); err != nil {
+				rows.Close()
+				return err
+			}
+			t, err := time.Parse(time.RFC3339, value.String)
+			if err != nil {
+				logger.Warn("Dropping invalid %s value %q of post %d", column, value.String, id)
+				converted[id] = nil
+				continue
+			}
+			// The connection reads and writes times in the local timezone.
+			formatted := t.In(time.Local).Format(mysql_datetime)
+			converted[id] = &formatted
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for id, value := range converted {
+			err := db.Table(posts_table).Where("id = ?", id).UpdateColumn(column, value).Error
+			if err != nil {
+				return err
+			}
+		}
+		if err := db.Model(&dbmodel.Post{}).ModifyColumn(column, "DATETIME NULL").Error; err != nil {
+			return err
+		}
+	}
+
+	err := db.Exec(fmt.Sprintf(
+		"UPDATE %s SET created_at = COALESCE(published_at, updated_at, NOW()) WHERE created_at IS NULL",
+		posts_table)).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(fmt.Sprintf(
+		"UPDATE %s SET updated_at = created_at WHERE updated_at IS NULL",
+		posts_table)).Error
+}
+
+// Posts created before statuses were introduced were all public. Keep
+// them that way instead of turning them into drafts.
+func migrate_publish_existing_posts(db *gorm.DB) error {
+	return db.Model(&dbmodel.Post{}).Unscoped().
+		Where("status = ?", dbmodel.PostStatus_Draft).
+		UpdateColumn("status", dbmodel.PostStatus_Published).Error
+}
+
+// Encrypted provider tokens no longer fit in the VARCHAR(255) columns
+// `AutoMigrate` created for them. The tokens already stored are left in
+// plaintext until `cmd/reencrypt_tokens` runs.
+func migrate_oauth_tokens_to_text(db *gorm.DB) error {
+	for _, column := range []string{"access_token", "refresh_token"} {
+		if err := db.Model(&dbmodel.OAuthToken{}).ModifyColumn(column, "TEXT NOT NULL").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Access tokens are issued with a millisecond precision, keep the same
+// precision for the cutoffs revoking them instead of whole seconds.
+func migrate_token_revocation_milliseconds(db *gorm.DB) error {
+	return db.Model(&dbmodel.UserTokenRevocation{}).ModifyColumn("revoked_before", "DATETIME(3) NOT NULL").Error
+}
@@ -56,7 +56,12 @@ func init_database() (*gorm.DB, error) {
 		return nil, err
 	}
 	create_db()
-	migrate_db()
+	err = migrate_db()
+	if err != nil {
+		_db_instance.Close()
+		_db_instance = nil
+		return nil, err
+	}
 	return _db_instance, nil
 }
 
@@ -94,12 +99,19 @@ func create_db() {
 	_db_instance.Exec(fmt.Sprintf("USE %s", _db_name))
 }
 
-func migrate_db() {
+func migrate_db() error {
 	logger.Info("Migrating %d model(s)", len(dbmodel.Models))
 	for _, model := range dbmodel.Models {
 		logger.Info("> Migrating model: %#v", model)
 		_db_instance.AutoMigrate(model)
 	}
 
+	logger.Info("Applying data migrations....")
+	err := run_data_migrations(_db_instance)
+	if err != nil {
+		return err
+	}
+
 	logger.Info("Database migration completed....")
+	return nil
 }
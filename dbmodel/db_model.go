@@ -2,14 +2,85 @@ package dbmodel
 
 import "time"
 
+type PostStatus int
+
+const (
+	PostStatus_Draft     PostStatus = 0
+	PostStatus_Scheduled PostStatus = 1
+	PostStatus_Published PostStatus = 2
+	PostStatus_Archived  PostStatus = 3
+)
+
 type Post struct {
-	ID           uint64 `sql:"AUTO_INCREMENT" gorm:"primary_key"`
-	Title        string `gorm:"not null"`
-	Content      string `gorm:"not null"`
-	Author       string `gorm:"not null; unique"`
-	Hero         string `json:"Hero"`
-	Published_At string `json:"PublishedAt"`
-	Updated_At   string `json:"UpdateAt"`
+	ID          uint64     `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	Title       string     `gorm:"not null;index"`
+	Content     string     `gorm:"not null"`
+	AuthorID    uint64     `gorm:"not null;index"`
+	Hero        string     `json:"Hero"`
+	Status      PostStatus `gorm:"not null;default:0;index"`
+	PublishedAt *time.Time `gorm:"index"`
+	// When a scheduled post will be published.
+	ScheduledAt *time.Time `gorm:"index"`
+	// Maintained by gorm when the post is created and saved.
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time `gorm:"index"`
+	// Set when the post is soft deleted; gorm leaves such rows out of
+	// queries unless they are explicitly `Unscoped`.
+	DeletedAt *time.Time `gorm:"index"`
+	// Associations are not saved along with the post; they are updated
+	// explicitly through `Association(...)`.
+	Tags       []Tag      `gorm:"many2many:post_tags;save_associations:false"`
+	Categories []Category `gorm:"many2many:post_categories;save_associations:false"`
+}
+
+// Free-form label attached to posts.
+type Tag struct {
+	ID        uint64 `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	Name      string `gorm:"not null"`
+	Slug      string `gorm:"not null;unique_index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Curated grouping of posts, managed by admins.
+type Category struct {
+	ID        uint64 `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	Name      string `gorm:"not null"`
+	Slug      string `gorm:"not null;unique_index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Immutable snapshot of a post, written every time the post is created
+// or edited. Revisions are numbered per post, starting at 1.
+type PostRevision struct {
+	ID       uint64 `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	PostID   uint64 `gorm:"not null;unique_index:idx_post_revision"`
+	Revision int    `gorm:"not null;unique_index:idx_post_revision"`
+	Title    string `gorm:"not null"`
+	Content  string `gorm:"type:text;not null"`
+	Hero     string
+	// The user that made the change. Null when it is unknown, e.g. for
+	// the snapshot of a post that existed before revisions were kept.
+	EditorID *uint64 `gorm:"index"`
+	// Comma separated list of the fields that changed in this revision.
+	ChangedFields string
+	// Set when this revision restores the content of an older revision.
+	RevertedFrom *int
+	CreatedAt    time.Time
+}
+
+// Reader response to a post. Comments with a parent are replies,
+// forming threads under the top-level comments of a post.
+type Comment struct {
+	ID        uint64  `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	PostID    uint64  `gorm:"not null;index"`
+	AuthorID  uint64  `gorm:"not null;index"`
+	ParentID  *uint64 `gorm:"index"`
+	Body      string  `gorm:"type:text;not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt *time.Time `gorm:"index"`
 }
 
 type UserType int
@@ -20,22 +91,92 @@ const (
 )
 
 type User struct {
-	ID         uint64       `sql:"AUTO_INCREMENT" gorm:"primaryKey"`
-	Email      string       `gorm:"index;unique"`
-	Password   string       `gorm:""`
-	Type       UserType     `gorm:"default:0"`
-	AuthTokens []OAuthToken `gorm:"foreignKey:UserId"`
+	ID          uint64       `sql:"AUTO_INCREMENT" gorm:"primaryKey"`
+	Email       string       `gorm:"index;unique"`
+	Password    string       `gorm:""`
+	Type        UserType     `gorm:"default:0"`
+	DisplayName string       `gorm:"size:100"`
+	AuthTokens  []OAuthToken `gorm:"foreignKey:UserId"`
 }
 
+// Tokens of a user at an OAuth provider. The tokens are encrypted in the
+// database, see oauth_token.go.
 type OAuthToken struct {
 	ID           uint64    `sql:"AUTO_INCREMENT" gorm:"primaryKey"`
 	Version      string    `gorm:"default:2"`
 	Provider     string    `gorm:"not null"`
-	AccessToken  string    `gorm:"not null"`
-	RefreshToken string    `gorm:"not null"`
+	AccessToken  string    `gorm:"type:text;not null"`
+	RefreshToken string    `gorm:"type:text;not null"`
 	Expiry       time.Time `gorm:"not null"`
 	LastRefresh  time.Time `gorm:"not null"`
 	UserId       uint64    `gorm:"index"`
+	// Id of the key-encryption key wrapping `DataKey`, empty for the tokens
+	// stored in plaintext.
+	KeyID   string `gorm:"size:64;not null;default:'';index"`
+	DataKey string `gorm:"size:128;not null;default:''"`
+}
+
+// Opaque token exchanged for a new access token. Only the SHA-256 hash
+// of the token is stored. Every refresh replaces the token with a new one
+// of the same family, so reusing a replaced token reveals it leaked.
+type RefreshToken struct {
+	ID        uint64    `sql:"AUTO_INCREMENT" gorm:"primary_key"`
+	UserID    uint64    `gorm:"not null;index"`
+	FamilyID  string    `gorm:"not null;index"`
+	TokenHash string    `gorm:"type:char(64);not null;unique_index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	// Set once the token has been exchanged for a new one.
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// Access token revoked before its expiry, identified by its `jti` claim.
+type RevokedToken struct {
+	TokenID   string    `gorm:"primary_key;type:varchar(64)"`
+	UserID    uint64    `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// Every access token of the session, identified by their `sid` claim, is
+// revoked. Kept until the last access token of the session expired.
+type RevokedSession struct {
+	SessionID string    `gorm:"primary_key;type:varchar(64)"`
+	UserID    uint64    `gorm:"not null;index"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// Every access token of the user issued up to RevokedBefore is revoked.
+type UserTokenRevocation struct {
+	UserID        uint64    `gorm:"primary_key;auto_increment:false"`
+	RevokedBefore time.Time `gorm:"type:datetime(3);not null;index"`
+}
+
+// OAuth state that completed a login, kept until it expires so the
+// callback can not be replayed with it.
+type UsedOAuthState struct {
+	State     string    `gorm:"primary_key;type:varchar(64)"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+// Query document registered through the automatic persisted query
+// protocol, keyed by the SHA-256 hash of the document.
+type PersistedQuery struct {
+	Hash      string `gorm:"primary_key;type:char(64)"`
+	Query     string `gorm:"type:mediumtext;not null"`
+	CreatedAt time.Time
+	// Refreshed at most once an hour when the query is used. Null for the
+	// queries persisted before it was tracked.
+	LastUsedAt *time.Time `gorm:"index"`
+}
+
+// Records a data migration that has been applied to the database.
+// See `database/migrations.go`.
+type SchemaMigration struct {
+	ID        string    `gorm:"primary_key"`
+	AppliedAt time.Time `gorm:"not null"`
 }
 
 // Models defined here will be auto migrated into the database
@@ -43,5 +184,16 @@ type OAuthToken struct {
 var Models = []interface{}{
 	&User{},
 	&OAuthToken{},
+	&RefreshToken{},
+	&RevokedToken{},
+	&RevokedSession{},
+	&UserTokenRevocation{},
+	&UsedOAuthState{},
 	&Post{},
+	&PostRevision{},
+	&Tag{},
+	&Category{},
+	&Comment{},
+	&PersistedQuery{},
+	&SchemaMigration{},
 }
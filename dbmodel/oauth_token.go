@@ -0,0 +1,89 @@
+package dbmodel
+
+import (
+	"fmt"
+	"go-graphql-api/util/envelope"
+	"strings"
+)
+
+// The provider tokens are encrypted right before being written, and
+// decrypted right after being read or written, so the rest of the code
+// only ever sees them in plaintext. Tokens are left in plaintext when no
+// encryption key is configured.
+//
+// The ciphertexts are bound to the owner of the row, so they can not be
+// moved to the row of another user. Rows encrypted before that have no
+// `DataKeyFormat_Owner` prefix on their data key, and are bound to the
+// field name only until `cmd/reencrypt_tokens` encrypts them again.
+
+// Prefix of the data keys of the tokens bound to their owner.
+const DataKeyFormat_Owner = "v2:"
+
+// Authenticated data of the `field` of the token, naming its owner. The
+// id of the row is not known yet when it is created.
+func (t *OAuthToken) encryption_context(field string) string {
+	return fmt.Sprintf("%s:%d:%s:%s", field, t.UserId, t.Provider, t.Version)
+}
+
+func (t *OAuthToken) BeforeSave() error {
+	if !envelope.Enabled() {
+		t.KeyID = ""
+		t.DataKey = ""
+		return nil
+	}
+	key, err := envelope.NewDataKey()
+	if err != nil {
+		return err
+	}
+	access_token, err := key.Encrypt(t.AccessToken, t.encryption_context("access_token"))
+	if err != nil {
+		return err
+	}
+	refresh_token, err := key.Encrypt(t.RefreshToken, t.encryption_context("refresh_token"))
+	if err != nil {
+		return err
+	}
+	t.AccessToken = access_token
+	t.RefreshToken = refresh_token
+	t.KeyID = key.KeyID
+	t.DataKey = DataKeyFormat_Owner + key.Wrapped
+	return nil
+}
+
+func (t *OAuthToken) AfterSave() error {
+	return t.decrypt()
+}
+
+func (t *OAuthToken) AfterFind() error {
+	return t.decrypt()
+}
+
+func (t *OAuthToken) decrypt() error {
+	// Stored in plaintext, or the tokens were not selected.
+	if len(t.KeyID) == 0 || len(t.DataKey) == 0 {
+		return nil
+	}
+	wrapped, bound_to_owner := strings.CutPrefix(t.DataKey, DataKeyFormat_Owner)
+	context := func(field string) string {
+		if bound_to_owner {
+			return t.encryption_context(field)
+		}
+		return field
+	}
+
+	key, err := envelope.OpenDataKey(t.KeyID, wrapped)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt tokens of oauth token %d: %v", t.ID, err)
+	}
+	access_token, err := key.Decrypt(t.AccessToken, context("access_token"))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt access token of oauth token %d: %v", t.ID, err)
+	}
+	refresh_token, err := key.Decrypt(t.RefreshToken, context("refresh_token"))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt refresh token of oauth token %d: %v", t.ID, err)
+	}
+	t.AccessToken = access_token
+	t.RefreshToken = refresh_token
+	return nil
+}
@@ -0,0 +1,148 @@
+package dbmodel
+
+import (
+	"bytes"
+	"encoding/base64"
+	"go-graphql-api/util/envelope"
+	"strings"
+	"testing"
+)
+
+func load_test_keys(t *testing.T, key byte) {
+	t.Setenv("TOKEN_ENCRYPTION_KEYS", "k1:"+base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{key}, 32)))
+	t.Setenv("TOKEN_ENCRYPTION_KEY_ID", "k1")
+	if err := envelope.LoadKeys(); err != nil {
+		t.Fatalf("LoadKeys() error = %v", err)
+	}
+}
+
+func new_test_token() *OAuthToken {
+	return &OAuthToken{
+		ID:           7,
+		Version:      "2",
+		Provider:     "google",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		UserId:       42,
+	}
+}
+
+// Tokens saved with `BeforeSave`, then read back after a change to the row.
+func TestOAuthTokenEncryption(t *testing.T) {
+	tests := []struct {
+		name string
+		// Change made to the stored row before it is read.
+		tamper   func(t *testing.T, token *OAuthToken)
+		want_err bool
+	}{
+		{
+			name:   "round trip",
+			tamper: func(t *testing.T, token *OAuthToken) {},
+		},
+		{
+			name:     "moved to another user",
+			tamper:   func(t *testing.T, token *OAuthToken) { token.UserId = 43 },
+			want_err: true,
+		},
+		{
+			name:     "moved to another provider",
+			tamper:   func(t *testing.T, token *OAuthToken) { token.Provider = "github" },
+			want_err: true,
+		},
+		{
+			name: "swapped fields",
+			tamper: func(t *testing.T, token *OAuthToken) {
+				token.AccessToken, token.RefreshToken = token.RefreshToken, token.AccessToken
+			},
+			want_err: true,
+		},
+		{
+			name:     "wrong key-encryption key",
+			tamper:   func(t *testing.T, token *OAuthToken) { load_test_keys(t, 2) },
+			want_err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			load_test_keys(t, 1)
+			token := new_test_token()
+			if err := token.BeforeSave(); err != nil {
+				t.Fatalf("BeforeSave() error = %v", err)
+			}
+			if token.AccessToken == "access" || token.RefreshToken == "refresh" {
+				t.Fatalf("tokens stored in plaintext")
+			}
+			if token.KeyID != "k1" || !strings.HasPrefix(token.DataKey, DataKeyFormat_Owner) {
+				t.Fatalf("KeyID = %q, DataKey = %q, want key k1 bound to the owner", token.KeyID, token.DataKey)
+			}
+
+			test.tamper(t, token)
+			err := token.AfterFind()
+			if (err != nil) != test.want_err {
+				t.Fatalf("AfterFind() error = %v, want error %v", err, test.want_err)
+			}
+			if err == nil && (token.AccessToken != "access" || token.RefreshToken != "refresh") {
+				t.Errorf("decrypted %q and %q, want %q and %q", token.AccessToken, token.RefreshToken, "access", "refresh")
+			}
+		})
+	}
+}
+
+// Rows encrypted before the tokens were bound to their owner are bound to
+// the field name only.
+func TestOAuthTokenLegacyDecryption(t *testing.T) {
+	load_test_keys(t, 1)
+
+	encrypt := func(t *testing.T, access_context string, refresh_context string) *OAuthToken {
+		key, err := envelope.NewDataKey()
+		if err != nil {
+			t.Fatalf("NewDataKey() error = %v", err)
+		}
+		token := new_test_token()
+		token.KeyID = key.KeyID
+		token.DataKey = key.Wrapped
+		if token.AccessToken, err = key.Encrypt("access", access_context); err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		if token.RefreshToken, err = key.Encrypt("refresh", refresh_context); err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name            string
+		access_context  string
+		refresh_context string
+		want_err        bool
+	}{
+		{name: "field name context", access_context: "access_token", refresh_context: "refresh_token"},
+		{name: "swapped fields", access_context: "refresh_token", refresh_context: "access_token", want_err: true},
+		{name: "owner context without prefix", access_context: "access_token:42:google:2", refresh_context: "refresh_token:42:google:2", want_err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token := encrypt(t, test.access_context, test.refresh_context)
+			err := token.AfterFind()
+			if (err != nil) != test.want_err {
+				t.Fatalf("AfterFind() error = %v, want error %v", err, test.want_err)
+			}
+			if err == nil && (token.AccessToken != "access" || token.RefreshToken != "refresh") {
+				t.Errorf("decrypted %q and %q, want %q and %q", token.AccessToken, token.RefreshToken, "access", "refresh")
+			}
+		})
+	}
+}
+
+// Tokens stored before encryption was configured are read as is.
+func TestOAuthTokenPlaintext(t *testing.T) {
+	token := new_test_token()
+	if err := token.AfterFind(); err != nil {
+		t.Fatalf("AfterFind() error = %v", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" {
+		t.Errorf("got %q and %q, want the tokens unchanged", token.AccessToken, token.RefreshToken)
+	}
+}
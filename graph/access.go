@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"context"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/gql_middleware"
+)
+
+// Get the authenticated user of the request, failing for anonymous
+// requests.
+func require_user(ctx context.Context) (*dbmodel.User, error) {
+	user := gql_middleware.UserFromContext(ctx)
+	if user == nil {
+		return nil, err_unauthenticated()
+	}
+	return user, nil
+}
+
+// Get the authenticated user of the request, failing unless they are
+// an admin.
+func require_admin(ctx context.Context) (*dbmodel.User, error) {
+	user, err := require_user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Type != dbmodel.UserType_Admin {
+		return nil, err_forbidden("admin privileges required")
+	}
+	return user, nil
+}
@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// Deepest comment thread that can be requested from `Post.comments`.
+	_comment_max_depth = util.EnvIntOrDefault("COMMENT_MAX_DEPTH", 5)
+	// Longest comment body accepted, in characters.
+	_comment_max_length = util.EnvIntOrDefault("COMMENT_MAX_LENGTH", 10000)
+)
+
+func comment_to_model(comment *dbmodel.Comment) *model.Comment {
+	result := model.Comment{
+		ID:        int(comment.ID),
+		PostID:    int(comment.PostID),
+		AuthorID:  comment.AuthorID,
+		Body:      comment.Body,
+		CreatedAt: comment.CreatedAt,
+		UpdatedAt: comment.UpdatedAt,
+		Replies:   []*model.Comment{},
+	}
+	if comment.ParentID != nil {
+		parent_id := int(*comment.ParentID)
+		result.ParentID = &parent_id
+	}
+	return &result
+}
+
+func validate_comment_body(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if len(body) == 0 {
+		return "", fmt.Errorf("a comment can not be empty")
+	}
+	if utf8.RuneCountInString(body) > _comment_max_length {
+		return "", fmt.Errorf("a comment can not be longer than %d characters", _comment_max_length)
+	}
+	return body, nil
+}
+
+// Clamp the requested thread depth to the supported range.
+func comment_thread_depth(depth *int) int {
+	if depth == nil {
+		return 1
+	}
+	if *depth < 1 {
+		return 1
+	}
+	if *depth > _comment_max_depth {
+		return _comment_max_depth
+	}
+	return *depth
+}
+
+func (r *Resolver) find_comment(id int) (*dbmodel.Comment, error) {
+	var comment dbmodel.Comment
+	err := r.Database.First(&comment, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, err_not_found("comment", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comment %d: %v", id, err)
+	}
+	return &comment, nil
+}
+
+// Fetch the page of the top-level comments of the post `post_id`. First
+// pages are fetched through the loaders, together with the pages of the
+// other posts of the request.
+func (r *Resolver) post_comments_page(ctx context.Context, post_id uint64, window *page_window) (*page[dbmodel.Comment], error) {
+	key := func(comment *dbmodel.Comment) uint64 { return comment.ID }
+	order := &page_order[dbmodel.Comment]{}
+	if window.is_first_page() {
+		first, err := r.loaders_for(ctx).RootCommentsByPostID.Load(ctx, first_page_key{post_id: post_id, limit: window.limit})
+		if err != nil {
+			return nil, err
+		}
+		return new_page(first.Rows, first.TotalCount, window, key, order), nil
+	}
+
+	query := r.Database.Model(&dbmodel.Comment{}).Where("post_id = ? AND parent_id IS NULL", post_id)
+	return paginate(query, window, "id", key, order)
+}
+
+// Attach the replies of the `roots` comments to them, `depth` levels
+// deep counting the roots as the first level, and count the direct
+// replies of every comment of the thread. Every level is loaded through
+// the loaders, so the threads of a list of posts are loaded together.
+func (r *Resolver) load_comment_thread(ctx context.Context, roots []*model.Comment, depth int) error {
+	loaders := r.loaders_for(ctx)
+	var loaded []*model.Comment
+	level := roots
+
+	for d := 1; len(level) > 0; d++ {
+		loaded = append(loaded, level...)
+		if d >= depth {
+			break
+		}
+
+		replies, err := loaders.RepliesByCommentID.LoadMany(ctx, comment_ids(level))
+		if err != nil {
+			return err
+		}
+		next := []*model.Comment{}
+		for i, parent := range level {
+			for j := range replies[i] {
+				reply := comment_to_model(&replies[i][j])
+				parent.Replies = append(parent.Replies, reply)
+				next = append(next, reply)
+			}
+		}
+		level = next
+	}
+
+	if len(loaded) == 0 {
+		return nil
+	}
+	counts, err := loaders.ReplyCountByCommentID.LoadMany(ctx, comment_ids(loaded))
+	if err != nil {
+		return err
+	}
+	for i, comment := range loaded {
+		comment.ReplyCount = counts[i]
+	}
+	return nil
+}
+
+func comment_ids(comments []*model.Comment) []uint64 {
+	ids := make([]uint64, 0, len(comments))
+	for _, comment := range comments {
+		ids = append(ids, uint64(comment.ID))
+	}
+	return ids
+}
+
+// Soft delete `comment` and every reply below it.
+func (r *Resolver) delete_comment_thread(comment *dbmodel.Comment) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		level := []uint64{comment.ID}
+		for len(level) > 0 {
+			var replies []uint64
+			if err := tx.Model(&dbmodel.Comment{}).Where("parent_id IN (?)", level).Pluck("id", &replies).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&dbmodel.Comment{}).Where("id IN (?)", level).UpdateColumn("deleted_at", now).Error
+			if err != nil {
+				return err
+			}
+			level = replies
+		}
+		return nil
+	})
+}
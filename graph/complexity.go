@@ -0,0 +1,61 @@
+package graph
+
+import "go-graphql-api/graph/model"
+
+// Number of items assumed for list fields that are not paginated.
+const _unpaginated_list_estimate = 10
+
+// Largest number of items a paginated field can return for the given
+// pagination arguments.
+func page_size(first *int, last *int) int {
+	size := _default_page_size
+	if first != nil {
+		size = *first
+	} else if last != nil {
+		size = *last
+	}
+	if size > _max_page_size {
+		size = _max_page_size
+	}
+	if size < 1 {
+		return 1
+	}
+	return size
+}
+
+// Register cost hints for the list fields, so that the complexity of an
+// operation grows with the number of objects it can return instead of
+// counting every field once.
+func ConfigureComplexity(c *ComplexityRoot) {
+	list := func(childComplexity int) int {
+		return _unpaginated_list_estimate * childComplexity
+	}
+
+	c.Query.GetAllPosts = func(childComplexity int) int {
+		// Unbounded; cost it like the largest page of posts.
+		return _max_page_size * childComplexity
+	}
+	c.Query.Posts = func(childComplexity int, first *int, after *string, last *int, before *string, filter *model.PostFilter, orderBy *model.PostOrder) int {
+		return page_size(first, last) * childComplexity
+	}
+	c.Query.Tags = list
+	c.Query.Categories = list
+
+	c.Post.Tags = list
+	c.Post.Categories = list
+	c.Post.Comments = func(childComplexity int, first *int, after *string, last *int, before *string, depth *int) int {
+		// Replies are only loaded up to the clamped depth, whatever the
+		// nesting of the selection: cost every level like a page of
+		// comments.
+		return page_size(first, last) * comment_thread_depth(depth) * childComplexity
+	}
+	c.Post.Revisions = func(childComplexity int, first *int, after *string, last *int, before *string) int {
+		return page_size(first, last) * childComplexity
+	}
+	c.Comment.Replies = func(childComplexity int) int {
+		// Already accounted for by the depth of `Post.comments`.
+		return childComplexity
+	}
+	c.User.Providers = list
+	c.PostRevisionDiff.Lines = list
+}
@@ -0,0 +1,63 @@
+package graph
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/vektah/gqlparser/v2"
+)
+
+const _reply_fields = `id body createdAt author { id displayName } replyCount`
+
+// Nest `levels` levels of replies under a comment selection.
+func nested_replies(levels int) string {
+	selection := _reply_fields
+	for i := 0; i < levels; i++ {
+		selection = _reply_fields + ` replies { ` + selection + ` }`
+	}
+	return selection
+}
+
+func operation_complexity(t *testing.T, query string) int {
+	config := Config{Resolvers: &Resolver{}}
+	ConfigureComplexity(&config.Complexity)
+	schema := NewExecutableSchema(config)
+
+	doc, errs := gqlparser.LoadQuery(schema.Schema(), query)
+	if errs != nil {
+		t.Fatalf("invalid query: %v", errs)
+	}
+	return complexity.Calculate(schema, doc.Operations[0], nil)
+}
+
+// Threads up to the supported depth must fit in the default budget, and
+// the cost must not grow with the nesting of the selection past it.
+func TestCommentThreadComplexity(t *testing.T) {
+	const default_max_complexity = 10000
+
+	tests := []struct {
+		name   string
+		depth  int
+		levels int
+	}{
+		{name: "default depth", depth: 3, levels: 2},
+		{name: "max depth", depth: _comment_max_depth, levels: 4},
+		{name: "clamped depth", depth: 50, levels: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := `{ GetOnePost(id: 1) { comments(first: 50, depth: ` + strconv.Itoa(test.depth) + `) { edges { node { ` + nested_replies(test.levels) + ` } } } } }`
+			if got := operation_complexity(t, query); got > default_max_complexity {
+				t.Errorf("complexity = %d, want at most %d", got, default_max_complexity)
+			}
+		})
+	}
+
+	shallow := operation_complexity(t, `{ GetOnePost(id: 1) { comments(first: 50, depth: 1) { edges { node { `+nested_replies(4)+` } } } } }`)
+	deep := operation_complexity(t, `{ GetOnePost(id: 1) { comments(first: 50, depth: 5) { edges { node { `+nested_replies(4)+` } } } } }`)
+	if shallow >= deep {
+		t.Errorf("complexity of depth 1 = %d, want less than depth 5 = %d", shallow, deep)
+	}
+}
@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"context"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Register the implementations of the directives declared in the schema.
+func ConfigureDirectives(d *DirectiveRoot) {
+	d.Authenticated = authenticated_directive
+	d.HasRole = has_role_directive
+}
+
+// @authenticated: fail with UNAUTHENTICATED for anonymous requests.
+func authenticated_directive(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	if _, err := require_user(ctx); err != nil {
+		return nil, err
+	}
+	return next(ctx)
+}
+
+// @hasRole(role): fail with UNAUTHENTICATED for anonymous requests and
+// with FORBIDDEN when the user is neither of type `role` nor an admin.
+func has_role_directive(ctx context.Context, obj interface{}, next graphql.Resolver, role model.UserType) (interface{}, error) {
+	user, err := require_user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.Type != dbmodel.UserType_Admin && user.Type != user_type_from_model(role) {
+		return nil, err_forbidden("%s privileges required", strings.ToLower(string(role)))
+	}
+	return next(ctx)
+}
@@ -0,0 +1,36 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes reported in the `extensions.code` field of GraphQL errors.
+const (
+	ErrCode_NotFound        = "NOT_FOUND"
+	ErrCode_Unauthenticated = "UNAUTHENTICATED"
+	ErrCode_Forbidden       = "FORBIDDEN"
+)
+
+// Build a GraphQL error carrying the given `code` in its extensions.
+func new_coded_error(code string, format string, a ...any) *gqlerror.Error {
+	return &gqlerror.Error{
+		Message: fmt.Sprintf(format, a...),
+		Extensions: map[string]interface{}{
+			"code": code,
+		},
+	}
+}
+
+func err_not_found(kind string, id any) *gqlerror.Error {
+	return new_coded_error(ErrCode_NotFound, "%s with id %v not found", kind, id)
+}
+
+func err_unauthenticated() *gqlerror.Error {
+	return new_coded_error(ErrCode_Unauthenticated, "authentication required")
+}
+
+func err_forbidden(format string, a ...any) *gqlerror.Error {
+	return new_coded_error(ErrCode_Forbidden, format, a...)
+}
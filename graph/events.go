@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"context"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util/gql_middleware"
+	"go-graphql-api/util/logger"
+	"go-graphql-api/util/pubsub"
+	"strconv"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Topic of the brokers that are not split per post.
+const _all_posts_topic = "*"
+
+// Events published by the resolvers and background jobs, and delivered
+// to the GraphQL subscriptions.
+type Events struct {
+	posts_published *pubsub.Broker[*dbmodel.Post]
+	posts_updated   *pubsub.Broker[*dbmodel.Post]
+	comments_added  *pubsub.Broker[*dbmodel.Comment]
+}
+
+func NewEvents() *Events {
+	return &Events{
+		posts_published: pubsub.NewBroker[*dbmodel.Post](),
+		posts_updated:   pubsub.NewBroker[*dbmodel.Post](),
+		comments_added:  pubsub.NewBroker[*dbmodel.Comment](),
+	}
+}
+
+func post_topic(post_id uint64) string {
+	return strconv.FormatUint(post_id, 10)
+}
+
+// Notify that `post` was published. This is also an update of the post.
+// Publishing an event on a nil `Events` is a no-op.
+func (e *Events) PostPublished(post *dbmodel.Post) {
+	if e == nil {
+		return
+	}
+	// Subscribers get their own copy so later changes to `post` by the
+	// caller do not race with the delivery.
+	published := *post
+	e.posts_published.Publish(_all_posts_topic, &published)
+	e.PostUpdated(post)
+}
+
+func (e *Events) PostUpdated(post *dbmodel.Post) {
+	if e == nil {
+		return
+	}
+	updated := *post
+	e.posts_updated.Publish(post_topic(post.ID), &updated)
+}
+
+func (e *Events) CommentAdded(comment *dbmodel.Comment) {
+	if e == nil {
+		return
+	}
+	added := *comment
+	e.comments_added.Publish(post_topic(comment.PostID), &added)
+}
+
+// What to do with an event of a subscription.
+type event_action int
+
+const (
+	event_forward event_action = iota
+	event_skip
+	// End the subscription, e.g. once its post is no longer visible.
+	event_end
+)
+
+// Forward the events of `in` to a channel of GraphQL models, until the
+// subscription context `ctx` is done or `convert` ends it. Events for
+// which `convert` returns `event_skip` are not forwarded.
+func forward_events[T any, M any](ctx context.Context, in <-chan T, convert func(T) (M, event_action)) <-chan M {
+	out := make(chan M, 1)
+	go func() {
+		defer close(out)
+		for event := range in {
+			converted, act := convert(event)
+			switch act {
+			case event_skip:
+				continue
+			case event_end:
+				return
+			}
+			select {
+			case out <- converted:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Forward the post events the subscriber can see. Posts that are not
+// visible, e.g. once unpublished or deleted, get the `hidden` action.
+func forward_post_events(ctx context.Context, in <-chan *dbmodel.Post, hidden event_action) <-chan *model.Post {
+	user := gql_middleware.UserFromContext(ctx)
+	return forward_events(ctx, in, func(post *dbmodel.Post) (*model.Post, event_action) {
+		if !post_visible_to(user, post) {
+			return nil, hidden
+		}
+		return post_to_model(post), event_forward
+	})
+}
+
+// Forward the comments added to the post `post_id`, as long as the
+// subscriber can see the post. The post is loaded again for every
+// comment since it may have been unpublished or deleted meanwhile.
+func (r *Resolver) forward_comment_events(ctx context.Context, post_id uint64, in <-chan *dbmodel.Comment) <-chan *model.Comment {
+	user := gql_middleware.UserFromContext(ctx)
+	return forward_events(ctx, in, func(comment *dbmodel.Comment) (*model.Comment, event_action) {
+		var post dbmodel.Post
+		err := r.Database.First(&post, post_id).Error
+		if gorm.IsRecordNotFoundError(err) {
+			return nil, event_end
+		}
+		if err != nil {
+			logger.Err("Failed to fetch post %d of comment %d: %v", post_id, comment.ID, err)
+			return nil, event_skip
+		}
+		if !post_visible_to(user, &post) {
+			return nil, event_end
+		}
+		return comment_to_model(comment), event_forward
+	})
+}
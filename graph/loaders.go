@@ -0,0 +1,283 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/dataloader"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type loaders_context_key struct{}
+
+const (
+	// How long loaders collect keys before querying the database.
+	_loader_wait = 2 * time.Millisecond
+	// Most keys queried at once by a loader.
+	_loader_max_batch = 100
+)
+
+// Per-request loaders, batching the lookups done while resolving the
+// fields of a list of objects into a single query per relation.
+type Loaders struct {
+	UserByID           *dataloader.Loader[uint64, *dbmodel.User]
+	PostByID           *dataloader.Loader[uint64, *dbmodel.Post]
+	TagsByPostID       *dataloader.Loader[uint64, []dbmodel.Tag]
+	CategoriesByPostID *dataloader.Loader[uint64, []dbmodel.Category]
+	ProvidersByUserID  *dataloader.Loader[uint64, []dbmodel.OAuthToken]
+	// First page of the top-level comments and of the revisions of posts.
+	RootCommentsByPostID *dataloader.Loader[first_page_key, *first_page[dbmodel.Comment]]
+	RevisionsByPostID    *dataloader.Loader[first_page_key, *first_page[dbmodel.PostRevision]]
+	// Replies of comments, and their count.
+	RepliesByCommentID    *dataloader.Loader[uint64, []dbmodel.Comment]
+	ReplyCountByCommentID *dataloader.Loader[uint64, int]
+}
+
+func NewLoaders(db *gorm.DB) *Loaders {
+	return &Loaders{
+		UserByID:           dataloader.New(fetch_by_id[dbmodel.User](db, func(u *dbmodel.User) uint64 { return u.ID }), _loader_wait, _loader_max_batch),
+		PostByID:           dataloader.New(fetch_by_id[dbmodel.Post](db, func(p *dbmodel.Post) uint64 { return p.ID }), _loader_wait, _loader_max_batch),
+		TagsByPostID:       dataloader.New(fetch_tags_by_post_id(db), _loader_wait, _loader_max_batch),
+		CategoriesByPostID: dataloader.New(fetch_categories_by_post_id(db), _loader_wait, _loader_max_batch),
+		ProvidersByUserID:  dataloader.New(fetch_providers_by_user_id(db), _loader_wait, _loader_max_batch),
+		RootCommentsByPostID: dataloader.New(
+			fetch_first_pages(db, &dbmodel.Comment{}, "parent_id IS NULL AND deleted_at IS NULL",
+				func(c *dbmodel.Comment) uint64 { return c.PostID }),
+			_loader_wait, _loader_max_batch),
+		RevisionsByPostID: dataloader.New(
+			fetch_first_pages(db, &dbmodel.PostRevision{}, "",
+				func(r *dbmodel.PostRevision) uint64 { return r.PostID }),
+			_loader_wait, _loader_max_batch),
+		RepliesByCommentID:    dataloader.New(fetch_replies_by_comment_id(db), _loader_wait, _loader_max_batch),
+		ReplyCountByCommentID: dataloader.New(fetch_reply_count_by_comment_id(db), _loader_wait, _loader_max_batch),
+	}
+}
+
+// Attach a fresh set of loaders to the context of every request.
+//
+// Websocket connections are skipped: they live as long as the connection
+// and would serve stale values from the loader caches. Resolvers use
+// uncached loaders for them instead, see `loaders_for`.
+func LoadersMiddleware(db *gorm.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx := context.WithValue(r.Context(), loaders_context_key{}, NewLoaders(db))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Get the loaders of the request, or a new set when the request has
+// none attached.
+func (r *Resolver) loaders_for(ctx context.Context) *Loaders {
+	if loaders, ok := ctx.Value(loaders_context_key{}).(*Loaders); ok {
+		return loaders
+	}
+	return NewLoaders(r.Database)
+}
+
+func fetch_by_id[T any](db *gorm.DB, id_of func(*T) uint64) dataloader.BatchFunc[uint64, *T] {
+	return func(ctx context.Context, ids []uint64) (map[uint64]*T, error) {
+		var rows []T
+		if err := db.Where("id IN (?)", ids).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		result := make(map[uint64]*T, len(rows))
+		for i := range rows {
+			result[id_of(&rows[i])] = &rows[i]
+		}
+		return result, nil
+	}
+}
+
+func fetch_tags_by_post_id(db *gorm.DB) dataloader.BatchFunc[uint64, []dbmodel.Tag] {
+	return func(ctx context.Context, post_ids []uint64) (map[uint64][]dbmodel.Tag, error) {
+		rows, err := db.Table("post_tags").
+			Select("post_tags.post_id, tags.id, tags.name, tags.slug, tags.created_at, tags.updated_at").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").
+			Where("post_tags.post_id IN (?)", post_ids).
+			Order("tags.name").
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		result := make(map[uint64][]dbmodel.Tag, len(post_ids))
+		for rows.Next() {
+			var post_id uint64
+			var tag dbmodel.Tag
+			if err := rows.Scan(&post_id, &tag.ID, &tag.Name, &tag.Slug, &tag.CreatedAt, &tag.UpdatedAt); err != nil {
+				return nil, err
+			}
+			result[post_id] = append(result[post_id], tag)
+		}
+		return result, rows.Err()
+	}
+}
+
+func fetch_categories_by_post_id(db *gorm.DB) dataloader.BatchFunc[uint64, []dbmodel.Category] {
+	return func(ctx context.Context, post_ids []uint64) (map[uint64][]dbmodel.Category, error) {
+		rows, err := db.Table("post_categories").
+			Select("post_categories.post_id, categories.id, categories.name, categories.slug, categories.created_at, categories.updated_at").
+			Joins("JOIN categories ON categories.id = post_categories.category_id").
+			Where("post_categories.post_id IN (?)", post_ids).
+			Order("categories.name").
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		result := make(map[uint64][]dbmodel.Category, len(post_ids))
+		for rows.Next() {
+			var post_id uint64
+			var category dbmodel.Category
+			if err := rows.Scan(&post_id, &category.ID, &category.Name, &category.Slug, &category.CreatedAt, &category.UpdatedAt); err != nil {
+				return nil, err
+			}
+			result[post_id] = append(result[post_id], category)
+		}
+		return result, rows.Err()
+	}
+}
+
+// Only the provider details are loaded, never the provider tokens.
+func fetch_providers_by_user_id(db *gorm.DB) dataloader.BatchFunc[uint64, []dbmodel.OAuthToken] {
+	return func(ctx context.Context, user_ids []uint64) (map[uint64][]dbmodel.OAuthToken, error) {
+		var tokens []dbmodel.OAuthToken
+		err := db.Select("id, version, provider, last_refresh, user_id").
+			Where("user_id IN (?)", user_ids).
+			Order("provider").
+			Find(&tokens).Error
+		if err != nil {
+			return nil, err
+		}
+
+		result := make(map[uint64][]dbmodel.OAuthToken, len(user_ids))
+		for _, token := range tokens {
+			result[token.UserId] = append(result[token.UserId], token)
+		}
+		return result, nil
+	}
+}
+
+// First page of a connection of a post: the first `limit` rows by id.
+type first_page_key struct {
+	post_id uint64
+	limit   int
+}
+
+// Rows of a first page, with one extra row when more pages follow, and
+// the total count of the connection.
+type first_page[T any] struct {
+	Rows       []T
+	TotalCount int
+}
+
+// Fetch the first pages of the rows of `model` matching `condition`, for
+// every post at once. The pages of the posts are cut from a single query
+// numbering the rows of every post.
+func fetch_first_pages[T any](db *gorm.DB, model *T, condition string, post_id_of func(*T) uint64) dataloader.BatchFunc[first_page_key, *first_page[T]] {
+	table := db.NewScope(model).TableName()
+	where := "post_id IN (?)"
+	if len(condition) > 0 {
+		where += " AND " + condition
+	}
+
+	return func(ctx context.Context, keys []first_page_key) (map[first_page_key]*first_page[T], error) {
+		result := make(map[first_page_key]*first_page[T], len(keys))
+		post_ids_by_limit := map[int][]uint64{}
+		var post_ids []uint64
+		for _, key := range keys {
+			result[key] = &first_page[T]{}
+			post_ids_by_limit[key.limit] = append(post_ids_by_limit[key.limit], key.post_id)
+			post_ids = append(post_ids, key.post_id)
+		}
+
+		for limit, limit_post_ids := range post_ids_by_limit {
+			var rows []T
+			err := db.Raw(fmt.Sprintf(`SELECT * FROM (
+				SELECT %s.*, ROW_NUMBER() OVER (PARTITION BY post_id ORDER BY id) AS page_position
+				FROM %s WHERE %s) AS ranked
+				WHERE page_position <= ? ORDER BY post_id, id`, table, table, where),
+				limit_post_ids, limit+1).Scan(&rows).Error
+			if err != nil {
+				return nil, err
+			}
+			for i := range rows {
+				page := result[first_page_key{post_id: post_id_of(&rows[i]), limit: limit}]
+				page.Rows = append(page.Rows, rows[i])
+			}
+		}
+
+		rows, err := db.Table(table).
+			Select("post_id, COUNT(*)").
+			Where(where, post_ids).
+			Group("post_id").
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		counts := map[uint64]int{}
+		for rows.Next() {
+			var post_id uint64
+			var count int
+			if err := rows.Scan(&post_id, &count); err != nil {
+				return nil, err
+			}
+			counts[post_id] = count
+		}
+		for key, page := range result {
+			page.TotalCount = counts[key.post_id]
+		}
+		return result, rows.Err()
+	}
+}
+
+func fetch_replies_by_comment_id(db *gorm.DB) dataloader.BatchFunc[uint64, []dbmodel.Comment] {
+	return func(ctx context.Context, comment_ids []uint64) (map[uint64][]dbmodel.Comment, error) {
+		var replies []dbmodel.Comment
+		if err := db.Where("parent_id IN (?)", comment_ids).Order("id").Find(&replies).Error; err != nil {
+			return nil, err
+		}
+		result := make(map[uint64][]dbmodel.Comment, len(comment_ids))
+		for _, reply := range replies {
+			result[*reply.ParentID] = append(result[*reply.ParentID], reply)
+		}
+		return result, nil
+	}
+}
+
+func fetch_reply_count_by_comment_id(db *gorm.DB) dataloader.BatchFunc[uint64, int] {
+	return func(ctx context.Context, comment_ids []uint64) (map[uint64]int, error) {
+		rows, err := db.Model(&dbmodel.Comment{}).
+			Select("parent_id, COUNT(*)").
+			Where("parent_id IN (?)", comment_ids).
+			Group("parent_id").
+			Rows()
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		result := make(map[uint64]int, len(comment_ids))
+		for rows.Next() {
+			var parent_id uint64
+			var count int
+			if err := rows.Scan(&parent_id, &count); err != nil {
+				return nil, err
+			}
+			result[parent_id] = count
+		}
+		return result, rows.Err()
+	}
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// The `DateTime` scalar is bound to `time.Time` in gqlgen.yml and is
+// exchanged with clients as an RFC 3339 string.
+
+func MarshalDateTime(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(t.UTC().Format(time.RFC3339)))
+	})
+}
+
+func UnmarshalDateTime(v interface{}) (time.Time, error) {
+	str, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("DateTime must be an RFC 3339 string, got %T", v)
+	}
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("DateTime must be an RFC 3339 string: %v", err)
+	}
+	return t, nil
+}
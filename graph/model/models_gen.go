@@ -2,27 +2,390 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type AuthSession struct {
+	AccessToken           string    `json:"accessToken"`
+	AccessTokenExpiresAt  time.Time `json:"accessTokenExpiresAt"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+}
+
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type Comment struct {
+	ID         int        `json:"id"`
+	PostID     int        `json:"postId"`
+	ParentID   *int       `json:"parentId,omitempty"`
+	Author     *User      `json:"author"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	Replies    []*Comment `json:"replies"`
+	ReplyCount int        `json:"replyCount"`
+	AuthorID   uint64     `json:"-"`
+}
+
+type CommentConnection struct {
+	Edges      []*CommentEdge `json:"edges"`
+	PageInfo   *PageInfo      `json:"pageInfo"`
+	TotalCount int            `json:"totalCount"`
+}
+
+type CommentEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *Comment `json:"node"`
+}
+
+type DiffLine struct {
+	Operation DiffOperation `json:"operation"`
+	Text      string        `json:"text"`
+}
+
+type LinkedProvider struct {
+	Provider  string    `json:"provider"`
+	LastLogin time.Time `json:"lastLogin"`
+}
+
 type Mutation struct {
 }
 
 type NewPost struct {
-	Title       string  `json:"Title"`
-	Content     string  `json:"Content"`
-	Author      *string `json:"Author,omitempty"`
-	Hero        *string `json:"Hero,omitempty"`
-	PublishedAt *string `json:"Published_At,omitempty"`
-	UpdatedAt   *string `json:"Updated_At,omitempty"`
+	Title       string     `json:"Title"`
+	Content     string     `json:"Content"`
+	Hero        *string    `json:"Hero,omitempty"`
+	PublishedAt *time.Time `json:"publishedAt,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Categories  []string   `json:"categories,omitempty"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor,omitempty"`
+	EndCursor       *string `json:"endCursor,omitempty"`
 }
 
 type Post struct {
-	ID          int    `json:"id"`
-	Title       string `json:"Title"`
-	Content     string `json:"Content"`
-	Author      string `json:"Author"`
-	Hero        string `json:"Hero"`
-	PublishedAt string `json:"Published_At"`
-	UpdatedAt   string `json:"Updated_At"`
+	ID          int                     `json:"id"`
+	Title       string                  `json:"Title"`
+	Content     string                  `json:"Content"`
+	Author      *User                   `json:"author"`
+	Hero        string                  `json:"Hero"`
+	Status      PostStatus              `json:"status"`
+	PublishedAt *time.Time              `json:"publishedAt,omitempty"`
+	ScheduledAt *time.Time              `json:"scheduledAt,omitempty"`
+	CreatedAt   time.Time               `json:"createdAt"`
+	UpdatedAt   time.Time               `json:"updatedAt"`
+	DeletedAt   *time.Time              `json:"deletedAt,omitempty"`
+	Tags        []*Tag                  `json:"tags"`
+	Categories  []*Category             `json:"categories"`
+	Comments    *CommentConnection      `json:"comments"`
+	Revisions   *PostRevisionConnection `json:"revisions,omitempty"`
+	AuthorID    uint64                  `json:"-"`
+}
+
+type PostConnection struct {
+	Edges      []*PostEdge `json:"edges"`
+	PageInfo   *PageInfo   `json:"pageInfo"`
+	TotalCount int         `json:"totalCount"`
+}
+
+type PostEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *Post  `json:"node"`
+}
+
+type PostFilter struct {
+	AuthorID        *int        `json:"authorId,omitempty"`
+	PublishedAfter  *time.Time  `json:"publishedAfter,omitempty"`
+	PublishedBefore *time.Time  `json:"publishedBefore,omitempty"`
+	Status          *PostStatus `json:"status,omitempty"`
+	Tag             *string     `json:"tag,omitempty"`
+	Category        *string     `json:"category,omitempty"`
+	TitleContains   *string     `json:"titleContains,omitempty"`
+	IncludeDeleted  *bool       `json:"includeDeleted,omitempty"`
+}
+
+type PostOrder struct {
+	Field     PostOrderField `json:"field"`
+	Direction OrderDirection `json:"direction"`
+}
+
+type PostRevision struct {
+	Revision      int       `json:"revision"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	Hero          string    `json:"hero"`
+	Editor        *User     `json:"editor,omitempty"`
+	ChangedFields []string  `json:"changedFields"`
+	RevertedFrom  *int      `json:"revertedFrom,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+	EditorID      *uint64   `json:"-"`
+	PostID        uint64    `json:"-"`
+}
+
+type PostRevisionConnection struct {
+	Edges      []*PostRevisionEdge `json:"edges"`
+	PageInfo   *PageInfo           `json:"pageInfo"`
+	TotalCount int                 `json:"totalCount"`
+}
+
+type PostRevisionDiff struct {
+	PostID       int         `json:"postId"`
+	FromRevision int         `json:"fromRevision"`
+	ToRevision   int         `json:"toRevision"`
+	TitleChanged bool        `json:"titleChanged"`
+	Lines        []*DiffLine `json:"lines"`
+}
+
+type PostRevisionEdge struct {
+	Cursor string        `json:"cursor"`
+	Node   *PostRevision `json:"node"`
 }
 
 type Query struct {
 }
+
+type Subscription struct {
+}
+
+type Tag struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type UpdateProfileInput struct {
+	DisplayName *string `json:"displayName,omitempty"`
+}
+
+type User struct {
+	ID          int               `json:"id"`
+	Email       *string           `json:"email,omitempty"`
+	Type        UserType          `json:"type"`
+	DisplayName *string           `json:"displayName,omitempty"`
+	Providers   []*LinkedProvider `json:"providers,omitempty"`
+}
+
+type DiffOperation string
+
+const (
+	DiffOperationEqual  DiffOperation = "EQUAL"
+	DiffOperationInsert DiffOperation = "INSERT"
+	DiffOperationDelete DiffOperation = "DELETE"
+)
+
+var AllDiffOperation = []DiffOperation{
+	DiffOperationEqual,
+	DiffOperationInsert,
+	DiffOperationDelete,
+}
+
+func (e DiffOperation) IsValid() bool {
+	switch e {
+	case DiffOperationEqual, DiffOperationInsert, DiffOperationDelete:
+		return true
+	}
+	return false
+}
+
+func (e DiffOperation) String() string {
+	return string(e)
+}
+
+func (e *DiffOperation) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = DiffOperation(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid DiffOperation", str)
+	}
+	return nil
+}
+
+func (e DiffOperation) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrderDirection string
+
+const (
+	OrderDirectionAsc  OrderDirection = "ASC"
+	OrderDirectionDesc OrderDirection = "DESC"
+)
+
+var AllOrderDirection = []OrderDirection{
+	OrderDirectionAsc,
+	OrderDirectionDesc,
+}
+
+func (e OrderDirection) IsValid() bool {
+	switch e {
+	case OrderDirectionAsc, OrderDirectionDesc:
+		return true
+	}
+	return false
+}
+
+func (e OrderDirection) String() string {
+	return string(e)
+}
+
+func (e *OrderDirection) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrderDirection(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrderDirection", str)
+	}
+	return nil
+}
+
+func (e OrderDirection) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostOrderField string
+
+const (
+	PostOrderFieldPublishedAt PostOrderField = "PUBLISHED_AT"
+	PostOrderFieldUpdatedAt   PostOrderField = "UPDATED_AT"
+	PostOrderFieldTitle       PostOrderField = "TITLE"
+)
+
+var AllPostOrderField = []PostOrderField{
+	PostOrderFieldPublishedAt,
+	PostOrderFieldUpdatedAt,
+	PostOrderFieldTitle,
+}
+
+func (e PostOrderField) IsValid() bool {
+	switch e {
+	case PostOrderFieldPublishedAt, PostOrderFieldUpdatedAt, PostOrderFieldTitle:
+		return true
+	}
+	return false
+}
+
+func (e PostOrderField) String() string {
+	return string(e)
+}
+
+func (e *PostOrderField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostOrderField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostOrderField", str)
+	}
+	return nil
+}
+
+func (e PostOrderField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type PostStatus string
+
+const (
+	PostStatusDraft     PostStatus = "DRAFT"
+	PostStatusScheduled PostStatus = "SCHEDULED"
+	PostStatusPublished PostStatus = "PUBLISHED"
+	PostStatusArchived  PostStatus = "ARCHIVED"
+)
+
+var AllPostStatus = []PostStatus{
+	PostStatusDraft,
+	PostStatusScheduled,
+	PostStatusPublished,
+	PostStatusArchived,
+}
+
+func (e PostStatus) IsValid() bool {
+	switch e {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived:
+		return true
+	}
+	return false
+}
+
+func (e PostStatus) String() string {
+	return string(e)
+}
+
+func (e *PostStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PostStatus", str)
+	}
+	return nil
+}
+
+func (e PostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type UserType string
+
+const (
+	UserTypeNormal UserType = "NORMAL"
+	UserTypeAdmin  UserType = "ADMIN"
+)
+
+var AllUserType = []UserType{
+	UserTypeNormal,
+	UserTypeAdmin,
+}
+
+func (e UserType) IsValid() bool {
+	switch e {
+	case UserTypeNormal, UserTypeAdmin:
+		return true
+	}
+	return false
+}
+
+func (e UserType) String() string {
+	return string(e)
+}
+
+func (e *UserType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = UserType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid UserType", str)
+	}
+	return nil
+}
+
+func (e UserType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -0,0 +1,254 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	// Page size used when neither `first` nor `last` is given.
+	_default_page_size = util.EnvIntOrDefault("DEFAULT_PAGE_SIZE", 20)
+	// Largest page a client can request; bigger requests are clamped.
+	_max_page_size = util.EnvIntOrDefault("MAX_PAGE_SIZE", 100)
+)
+
+const _cursor_prefix = "cursor:"
+
+// Position of a row inside an ordered connection.
+//
+// Cursors are opaque to clients. Internally they hold the primary key
+// of the row they point to along with the value of the column the
+// connection is sorted by, which keeps them stable while rows are
+// added or removed around them.
+type cursor struct {
+	id    uint64
+	value string
+}
+
+func encode_cursor(c cursor) string {
+	raw := _cursor_prefix + strconv.FormatUint(c.id, 10) + ":" + c.value
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decode_cursor(encoded string) (*cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !strings.HasPrefix(string(raw), _cursor_prefix) {
+		return nil, fmt.Errorf("invalid cursor: %q", encoded)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(string(raw), _cursor_prefix), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor: %q", encoded)
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %q", encoded)
+	}
+	return &cursor{id: id, value: parts[1]}, nil
+}
+
+// Ordering of a connection. Rows are sorted by `column` and then by
+// their primary key, so that every row has a unique position.
+// Leaving `column` empty orders by the primary key alone.
+type page_order[T any] struct {
+	column string
+	desc   bool
+	// Value of `column` for a row, stored in the row's cursor.
+	value_of func(*T) string
+	// Convert a value stored in a cursor back into a query parameter.
+	// The raw string is used when this is nil.
+	parse_value func(string) (interface{}, error)
+}
+
+func (o *page_order[T]) cursor_of(key func(*T) uint64, row *T) cursor {
+	c := cursor{id: key(row)}
+	if o.column != "" {
+		c.value = o.value_of(row)
+	}
+	return c
+}
+
+// Build the condition selecting rows strictly after (`greater`) or
+// strictly before the position of `c`.
+func (o *page_order[T]) keyset_condition(key_column string, c *cursor, greater bool) (string, []interface{}, error) {
+	op := "<"
+	if greater {
+		op = ">"
+	}
+	if o.column == "" {
+		return fmt.Sprintf("%s %s ?", key_column, op), []interface{}{c.id}, nil
+	}
+
+	var value interface{} = c.value
+	if o.parse_value != nil {
+		parsed, err := o.parse_value(c.value)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid cursor for the requested ordering")
+		}
+		value = parsed
+	}
+	condition := fmt.Sprintf("(%[1]s %[3]s ? OR (%[1]s = ? AND %[2]s %[3]s ?))", o.column, key_column, op)
+	return condition, []interface{}{value, value, c.id}, nil
+}
+
+// Cursor values for time columns keep the full precision of the
+// timestamp so rows are not skipped between pages.
+func format_cursor_time(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func parse_cursor_time(value string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// The slice of a connection requested through the relay
+// `first`/`after`/`last`/`before` arguments.
+type page_window struct {
+	limit    int
+	backward bool
+	after    *cursor
+	before   *cursor
+}
+
+func new_page_window(first *int, after *string, last *int, before *string) (*page_window, error) {
+	if first != nil && last != nil {
+		return nil, fmt.Errorf("passing both `first` and `last` to paginate a connection is not supported")
+	}
+
+	window := page_window{limit: _default_page_size}
+	if first != nil {
+		if *first < 0 {
+			return nil, fmt.Errorf("`first` must not be negative")
+		}
+		window.limit = *first
+	}
+	if last != nil {
+		if *last < 0 {
+			return nil, fmt.Errorf("`last` must not be negative")
+		}
+		window.limit = *last
+		window.backward = true
+	}
+	if window.limit > _max_page_size {
+		window.limit = _max_page_size
+	}
+
+	var err error
+	if after != nil {
+		if window.after, err = decode_cursor(*after); err != nil {
+			return nil, err
+		}
+	}
+	if before != nil {
+		if window.before, err = decode_cursor(*before); err != nil {
+			return nil, err
+		}
+	}
+	return &window, nil
+}
+
+// Restrict `query` to the rows inside the window, sorted by `order`.
+// One extra row is requested so that we can tell if more pages follow.
+func apply_page_window[T any](query *gorm.DB, w *page_window, key_column string, order *page_order[T]) (*gorm.DB, error) {
+	if w.after != nil {
+		condition, args, err := order.keyset_condition(key_column, w.after, !order.desc)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+	if w.before != nil {
+		condition, args, err := order.keyset_condition(key_column, w.before, order.desc)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(condition, args...)
+	}
+
+	// Paging backwards fetches the rows in reverse order, starting from
+	// the `before` cursor.
+	direction := "ASC"
+	if order.desc != w.backward {
+		direction = "DESC"
+	}
+	if order.column != "" {
+		query = query.Order(order.column + " " + direction)
+	}
+	query = query.Order(key_column + " " + direction)
+	return query.Limit(w.limit + 1), nil
+}
+
+// A page of rows fetched for a connection.
+type page[T any] struct {
+	Rows       []T
+	Cursors    []string
+	PageInfo   *model.PageInfo
+	TotalCount int
+}
+
+// Fetch the page of `query` selected by `window`. `query` should contain
+// the filtering for the connection but no ordering or limits.
+func paginate[T any](query *gorm.DB, window *page_window, key_column string, key func(*T) uint64, order *page_order[T]) (*page[T], error) {
+	result := page[T]{PageInfo: &model.PageInfo{}}
+
+	var model_instance T
+	if err := query.Model(&model_instance).Count(&result.TotalCount).Error; err != nil {
+		return nil, err
+	}
+
+	windowed, err := apply_page_window(query, window, key_column, order)
+	if err != nil {
+		return nil, err
+	}
+	var rows []T
+	if err := windowed.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return new_page(rows, result.TotalCount, window, key, order), nil
+}
+
+// Whether the page of `window` can be served by the loaders of the
+// first page of a connection: a plain `first` without cursors.
+func (w *page_window) is_first_page() bool {
+	return !w.backward && w.after == nil && w.before == nil
+}
+
+// Build the page of `window` from the `rows` fetched for it, including
+// the extra row telling if more pages follow, and the `total_count` of
+// the connection.
+func new_page[T any](rows []T, total_count int, window *page_window, key func(*T) uint64, order *page_order[T]) *page[T] {
+	result := page[T]{PageInfo: &model.PageInfo{}, TotalCount: total_count}
+
+	has_more := len(rows) > window.limit
+	if has_more {
+		rows = rows[:window.limit]
+	}
+	if window.backward {
+		// Restore the requested order.
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		result.PageInfo.HasPreviousPage = has_more
+		result.PageInfo.HasNextPage = window.before != nil
+	} else {
+		result.PageInfo.HasNextPage = has_more
+		result.PageInfo.HasPreviousPage = window.after != nil
+	}
+
+	result.Rows = rows
+	result.Cursors = make([]string, len(rows))
+	for i := range rows {
+		result.Cursors[i] = encode_cursor(order.cursor_of(key, &rows[i]))
+	}
+	if len(rows) > 0 {
+		result.PageInfo.StartCursor = &result.Cursors[0]
+		result.PageInfo.EndCursor = &result.Cursors[len(rows)-1]
+	}
+	return &result
+}
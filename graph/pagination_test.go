@@ -0,0 +1,263 @@
+package graph
+
+import (
+	"encoding/base64"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"reflect"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	round_trips := []cursor{
+		{id: 1, value: "title"},
+		{id: 42, value: ""},
+		{id: 18446744073709551615, value: "max id"},
+		{id: 7, value: "2024-01-31T18:30:00.123456789Z"},
+		{id: 8, value: "a:b::c:"},
+	}
+	for _, c := range round_trips {
+		decoded, err := decode_cursor(encode_cursor(c))
+		if err != nil {
+			t.Errorf("decode_cursor(encode_cursor(%+v)) error = %v", c, err)
+		} else if *decoded != c {
+			t.Errorf("decode_cursor(encode_cursor(%+v)) = %+v", c, *decoded)
+		}
+	}
+
+	malformed := map[string]string{
+		"empty":             "",
+		"not base64":        "not a cursor!",
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte("cursor:1:a")),
+		"missing prefix":    raw("1:title"),
+		"other prefix":      raw("offset:1:title"),
+		"missing separator": raw("cursor:1"),
+		"missing id":        raw("cursor::title"),
+		"negative id":       raw("cursor:-1:title"),
+		"id not a number":   raw("cursor:one:title"),
+		"id out of range":   raw("cursor:18446744073709551616:title"),
+	}
+	for name, encoded := range malformed {
+		if c, err := decode_cursor(encoded); err == nil {
+			t.Errorf("%s: decode_cursor(%q) = %+v, want error", name, encoded, *c)
+		}
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	by_title := &page_order[dbmodel.Tag]{column: "name"}
+	by_time := &page_order[dbmodel.Tag]{column: "created_at", parse_value: parse_cursor_time}
+	by_id := &page_order[dbmodel.Tag]{}
+
+	tests := []struct {
+		name           string
+		order          *page_order[dbmodel.Tag]
+		cursor         cursor
+		greater        bool
+		want_condition string
+		want_args      []interface{}
+		want_err       bool
+	}{
+		{
+			name:           "primary key after",
+			order:          by_id,
+			cursor:         cursor{id: 3},
+			greater:        true,
+			want_condition: "id > ?",
+			want_args:      []interface{}{uint64(3)},
+		},
+		{
+			name:           "primary key before",
+			order:          by_id,
+			cursor:         cursor{id: 3},
+			want_condition: "id < ?",
+			want_args:      []interface{}{uint64(3)},
+		},
+		{
+			name:           "column after",
+			order:          by_title,
+			cursor:         cursor{id: 3, value: "b"},
+			greater:        true,
+			want_condition: "(name > ? OR (name = ? AND id > ?))",
+			want_args:      []interface{}{"b", "b", uint64(3)},
+		},
+		{
+			name:           "column before",
+			order:          by_title,
+			cursor:         cursor{id: 3, value: "b"},
+			want_condition: "(name < ? OR (name = ? AND id < ?))",
+			want_args:      []interface{}{"b", "b", uint64(3)},
+		},
+		{
+			name:     "unparsable value",
+			order:    by_time,
+			cursor:   cursor{id: 3, value: "yesterday"},
+			want_err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			condition, args, err := test.order.keyset_condition("id", &test.cursor, test.greater)
+			if (err != nil) != test.want_err {
+				t.Fatalf("keyset_condition() error = %v, want error %v", err, test.want_err)
+			}
+			if condition != test.want_condition || !reflect.DeepEqual(args, test.want_args) {
+				t.Errorf("keyset_condition() = %q %v, want %q %v", condition, args, test.want_condition, test.want_args)
+			}
+		})
+	}
+}
+
+// Page through tags sorted by name in an in-memory SQLite database, with
+// two tags sharing a name so the primary key breaks the tie.
+func TestPaginate(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(&dbmodel.Tag{}).Error; err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	for i, name := range []string{"a", "b", "b", "c", "d"} {
+		tag := dbmodel.Tag{ID: uint64(i + 1), Name: name, Slug: fmt.Sprintf("tag-%d", i+1)}
+		if err := db.Create(&tag).Error; err != nil {
+			t.Fatalf("failed to create tag: %v", err)
+		}
+	}
+
+	key := func(tag *dbmodel.Tag) uint64 { return tag.ID }
+	at := func(id uint64, name string) *string {
+		encoded := encode_cursor(cursor{id: id, value: name})
+		return &encoded
+	}
+	two := 2
+
+	tests := []struct {
+		name          string
+		desc          bool
+		first         *int
+		after         *string
+		last          *int
+		before        *string
+		want_ids      []uint64
+		want_next     bool
+		want_previous bool
+	}{
+		{name: "ASC first page", first: &two, want_ids: []uint64{1, 2}, want_next: true},
+		{name: "ASC after", first: &two, after: at(2, "b"), want_ids: []uint64{3, 4}, want_next: true, want_previous: true},
+		{name: "ASC after to the end", first: &two, after: at(4, "c"), want_ids: []uint64{5}, want_previous: true},
+		{name: "ASC last page", last: &two, want_ids: []uint64{4, 5}, want_previous: true},
+		{name: "ASC before", last: &two, before: at(4, "c"), want_ids: []uint64{2, 3}, want_next: true, want_previous: true},
+		{name: "ASC before to the start", last: &two, before: at(2, "b"), want_ids: []uint64{1}, want_next: true},
+		{name: "DESC first page", desc: true, first: &two, want_ids: []uint64{5, 4}, want_next: true},
+		{name: "DESC after", desc: true, first: &two, after: at(4, "c"), want_ids: []uint64{3, 2}, want_next: true, want_previous: true},
+		{name: "DESC after to the end", desc: true, first: &two, after: at(2, "b"), want_ids: []uint64{1}, want_previous: true},
+		{name: "DESC last page", desc: true, last: &two, want_ids: []uint64{2, 1}, want_previous: true},
+		{name: "DESC before", desc: true, last: &two, before: at(2, "b"), want_ids: []uint64{4, 3}, want_next: true, want_previous: true},
+		{name: "DESC before to the start", desc: true, last: &two, before: at(4, "c"), want_ids: []uint64{5}, want_next: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			window, err := new_page_window(test.first, test.after, test.last, test.before)
+			if err != nil {
+				t.Fatalf("new_page_window() error = %v", err)
+			}
+			order := &page_order[dbmodel.Tag]{
+				column:   "name",
+				desc:     test.desc,
+				value_of: func(tag *dbmodel.Tag) string { return tag.Name },
+			}
+			result, err := paginate(db.Model(&dbmodel.Tag{}), window, "id", key, order)
+			if err != nil {
+				t.Fatalf("paginate() error = %v", err)
+			}
+
+			ids := []uint64{}
+			for _, tag := range result.Rows {
+				ids = append(ids, tag.ID)
+			}
+			if !reflect.DeepEqual(ids, test.want_ids) {
+				t.Errorf("ids = %v, want %v", ids, test.want_ids)
+			}
+			if result.PageInfo.HasNextPage != test.want_next {
+				t.Errorf("hasNextPage = %v, want %v", result.PageInfo.HasNextPage, test.want_next)
+			}
+			if result.PageInfo.HasPreviousPage != test.want_previous {
+				t.Errorf("hasPreviousPage = %v, want %v", result.PageInfo.HasPreviousPage, test.want_previous)
+			}
+			if result.TotalCount != 5 {
+				t.Errorf("totalCount = %d, want 5", result.TotalCount)
+			}
+		})
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	key := func(tag *dbmodel.Tag) uint64 { return tag.ID }
+	order := &page_order[dbmodel.Tag]{}
+	tags := func(ids ...uint64) []dbmodel.Tag {
+		rows := []dbmodel.Tag{}
+		for _, id := range ids {
+			rows = append(rows, dbmodel.Tag{ID: id})
+		}
+		return rows
+	}
+	c := &cursor{id: 10}
+
+	tests := []struct {
+		name          string
+		window        page_window
+		rows          []dbmodel.Tag
+		want_ids      []uint64
+		want_next     bool
+		want_previous bool
+	}{
+		{name: "empty", window: page_window{limit: 2}, rows: tags(), want_ids: []uint64{}},
+		{name: "single page", window: page_window{limit: 2}, rows: tags(1, 2), want_ids: []uint64{1, 2}},
+		{name: "more pages follow", window: page_window{limit: 2}, rows: tags(1, 2, 3), want_ids: []uint64{1, 2}, want_next: true},
+		{name: "after a cursor", window: page_window{limit: 2, after: c}, rows: tags(11, 12), want_ids: []uint64{11, 12}, want_previous: true},
+		{name: "backward", window: page_window{limit: 2, backward: true}, rows: tags(9, 8), want_ids: []uint64{8, 9}},
+		{name: "backward with more pages", window: page_window{limit: 2, backward: true}, rows: tags(9, 8, 7), want_ids: []uint64{8, 9}, want_previous: true},
+		{name: "backward before a cursor", window: page_window{limit: 2, backward: true, before: c}, rows: tags(9, 8), want_ids: []uint64{8, 9}, want_next: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := new_page(test.rows, 0, &test.window, key, order)
+
+			ids := []uint64{}
+			for _, tag := range result.Rows {
+				ids = append(ids, tag.ID)
+			}
+			if !reflect.DeepEqual(ids, test.want_ids) {
+				t.Errorf("ids = %v, want %v", ids, test.want_ids)
+			}
+			if result.PageInfo.HasNextPage != test.want_next || result.PageInfo.HasPreviousPage != test.want_previous {
+				t.Errorf("hasNextPage = %v, hasPreviousPage = %v, want %v and %v",
+					result.PageInfo.HasNextPage, result.PageInfo.HasPreviousPage, test.want_next, test.want_previous)
+			}
+			if len(ids) == 0 {
+				if result.PageInfo.StartCursor != nil || result.PageInfo.EndCursor != nil {
+					t.Errorf("empty page has cursors")
+				}
+				return
+			}
+			start, err := decode_cursor(*result.PageInfo.StartCursor)
+			if err != nil || start.id != ids[0] {
+				t.Errorf("startCursor = %v, %v, want id %d", start, err, ids[0])
+			}
+			end, err := decode_cursor(*result.PageInfo.EndCursor)
+			if err != nil || end.id != ids[len(ids)-1] {
+				t.Errorf("endCursor = %v, %v, want id %d", end, err, ids[len(ids)-1])
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package graph
+
+import (
+	"context"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/gql_middleware"
+)
+
+// Actions the mutations perform on a resource, checked against the
+// policies of the resource type.
+type action string
+
+const (
+	action_update  action = "update"
+	action_delete  action = "delete"
+	action_restore action = "restore"
+	action_publish action = "publish"
+	action_purge   action = "purge"
+	// Read the revisions of a post, including its unpublished drafts.
+	action_view_history action = "view the history of"
+)
+
+// Decide whether `user` can perform an action on `resource`. `user` is
+// nil for anonymous requests.
+type policy[T any] func(user *dbmodel.User, resource *T) bool
+
+// Actions missing from a policy map are denied.
+var (
+	_post_policies = map[action]policy[dbmodel.Post]{
+		action_update:       is_post_author_or_admin,
+		action_delete:       is_post_author_or_admin,
+		action_restore:      is_post_author_or_admin,
+		action_publish:      is_post_author_or_admin,
+		action_purge:        is_admin[dbmodel.Post],
+		action_view_history: is_post_author_or_admin,
+	}
+	_comment_policies = map[action]policy[dbmodel.Comment]{
+		action_update: is_comment_author,
+		action_delete: is_comment_author_or_admin,
+	}
+)
+
+func is_admin[T any](user *dbmodel.User, _ *T) bool {
+	return user != nil && user.Type == dbmodel.UserType_Admin
+}
+
+func is_post_author_or_admin(user *dbmodel.User, post *dbmodel.Post) bool {
+	return user != nil && (user.ID == post.AuthorID || user.Type == dbmodel.UserType_Admin)
+}
+
+func is_comment_author(user *dbmodel.User, comment *dbmodel.Comment) bool {
+	return user != nil && user.ID == comment.AuthorID
+}
+
+func is_comment_author_or_admin(user *dbmodel.User, comment *dbmodel.Comment) bool {
+	return is_comment_author(user, comment) || is_admin(user, comment)
+}
+
+// Check that the user of the request can perform `act` on `resource`
+// according to `policies`. Denied requests fail with UNAUTHENTICATED for
+// anonymous users, and FORBIDDEN otherwise.
+func authorize[T any](ctx context.Context, policies map[action]policy[T], kind string, act action, resource *T) error {
+	user := gql_middleware.UserFromContext(ctx)
+	if allowed, ok := policies[act]; ok && allowed(user, resource) {
+		return nil
+	}
+	if user == nil {
+		return err_unauthenticated()
+	}
+	return err_forbidden("not allowed to %s this %s", act, kind)
+}
+
+func authorize_post(ctx context.Context, act action, post *dbmodel.Post) error {
+	return authorize(ctx, _post_policies, "post", act, post)
+}
+
+func authorize_comment(ctx context.Context, act action, comment *dbmodel.Comment) error {
+	return authorize(ctx, _comment_policies, "comment", act, comment)
+}
@@ -0,0 +1,318 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util/gql_middleware"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Convert a database post record into its GraphQL representation.
+func post_to_model(post *dbmodel.Post) *model.Post {
+	return &model.Post{
+		ID:          int(post.ID),
+		Title:       post.Title,
+		Content:     post.Content,
+		AuthorID:    post.AuthorID,
+		Hero:        post.Hero,
+		Status:      post_status_to_model(post.Status),
+		PublishedAt: post.PublishedAt,
+		ScheduledAt: post.ScheduledAt,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		DeletedAt:   post.DeletedAt,
+	}
+}
+
+var _post_status_to_model = map[dbmodel.PostStatus]model.PostStatus{
+	dbmodel.PostStatus_Draft:     model.PostStatusDraft,
+	dbmodel.PostStatus_Scheduled: model.PostStatusScheduled,
+	dbmodel.PostStatus_Published: model.PostStatusPublished,
+	dbmodel.PostStatus_Archived:  model.PostStatusArchived,
+}
+
+func post_status_to_model(status dbmodel.PostStatus) model.PostStatus {
+	return _post_status_to_model[status]
+}
+
+func post_status_from_model(status model.PostStatus) dbmodel.PostStatus {
+	for db_status, model_status := range _post_status_to_model {
+		if model_status == status {
+			return db_status
+		}
+	}
+	return dbmodel.PostStatus_Draft
+}
+
+func posts_to_model(posts []dbmodel.Post) []*model.Post {
+	result := make([]*model.Post, 0, len(posts))
+	for i := range posts {
+		result = append(result, post_to_model(&posts[i]))
+	}
+	return result
+}
+
+// Escape the wildcard characters of a LIKE pattern so `text` is
+// matched literally.
+var _like_escaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Narrow down `query` to the posts matching `filter`.
+func apply_post_filter(query *gorm.DB, filter *model.PostFilter) (*gorm.DB, error) {
+	if filter == nil {
+		return query, nil
+	}
+	if filter.AuthorID != nil {
+		query = query.Where("author_id = ?", *filter.AuthorID)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", post_status_from_model(*filter.Status))
+	}
+	if filter.PublishedAfter != nil {
+		query = query.Where("published_at >= ?", *filter.PublishedAfter)
+	}
+	if filter.PublishedBefore != nil {
+		query = query.Where("published_at < ?", *filter.PublishedBefore)
+	}
+	if filter.IncludeDeleted != nil && *filter.IncludeDeleted {
+		query = query.Unscoped()
+	}
+	if filter.Tag != nil {
+		query = query.Where(
+			"id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE tags.slug = ?)",
+			*filter.Tag)
+	}
+	if filter.Category != nil {
+		query = query.Where(
+			"id IN (SELECT post_categories.post_id FROM post_categories JOIN categories ON categories.id = post_categories.category_id WHERE categories.slug = ?)",
+			*filter.Category)
+	}
+	if filter.TitleContains != nil && len(*filter.TitleContains) > 0 {
+		query = query.Where("title LIKE ?", "%"+_like_escaper.Replace(*filter.TitleContains)+"%")
+	}
+	return query, nil
+}
+
+// Translate the requested post ordering into the pagination ordering.
+// Without an explicit order, posts are listed by id.
+func post_page_order(order *model.PostOrder) *page_order[dbmodel.Post] {
+	if order == nil {
+		return &page_order[dbmodel.Post]{}
+	}
+
+	result := page_order[dbmodel.Post]{desc: order.Direction == model.OrderDirectionDesc}
+	switch order.Field {
+	case model.PostOrderFieldPublishedAt:
+		// Posts without a publication date sort by their creation date.
+		result.column = "COALESCE(published_at, created_at)"
+		result.value_of = func(p *dbmodel.Post) string {
+			if p.PublishedAt != nil {
+				return format_cursor_time(*p.PublishedAt)
+			}
+			return format_cursor_time(p.CreatedAt)
+		}
+		result.parse_value = parse_cursor_time
+	case model.PostOrderFieldUpdatedAt:
+		result.column = "updated_at"
+		result.value_of = func(p *dbmodel.Post) string { return format_cursor_time(p.UpdatedAt) }
+		result.parse_value = parse_cursor_time
+	case model.PostOrderFieldTitle:
+		result.column = "title"
+		result.value_of = func(p *dbmodel.Post) string { return p.Title }
+	}
+	return &result
+}
+
+func post_page_to_connection(page *page[dbmodel.Post]) *model.PostConnection {
+	edges := make([]*model.PostEdge, 0, len(page.Rows))
+	for i := range page.Rows {
+		edges = append(edges, &model.PostEdge{
+			Cursor: page.Cursors[i],
+			Node:   post_to_model(&page.Rows[i]),
+		})
+	}
+	return &model.PostConnection{
+		Edges:      edges,
+		PageInfo:   page.PageInfo,
+		TotalCount: page.TotalCount,
+	}
+}
+
+// How far in the future a client supplied publication date can be,
+// to allow for clocks that are slightly off.
+const _published_at_tolerance = 5 * time.Minute
+
+func validate_published_at(published_at time.Time) error {
+	if published_at.Before(time.Unix(0, 0)) {
+		return fmt.Errorf("publishedAt must not be before 1970-01-01T00:00:00Z")
+	}
+	if published_at.After(time.Now().Add(_published_at_tolerance)) {
+		return fmt.Errorf("publishedAt must not be in the future")
+	}
+	return nil
+}
+
+// Copy the fields supplied in `input` onto `post`. Optional fields
+// that were left out of the input keep their current value.
+func apply_post_input(post *dbmodel.Post, input *model.NewPost) error {
+	if input.PublishedAt != nil {
+		if err := validate_published_at(*input.PublishedAt); err != nil {
+			return err
+		}
+		post.PublishedAt = input.PublishedAt
+	}
+
+	post.Title = input.Title
+	post.Content = input.Content
+	if input.Hero != nil {
+		post.Hero = *input.Hero
+	}
+	return nil
+}
+
+// Restrict `query` to the posts the user of the request can see.
+// Anonymous users only see published posts, authenticated users also
+// see their own posts, and admins see everything.
+func scope_visible_posts(ctx context.Context, query *gorm.DB) *gorm.DB {
+	user := gql_middleware.UserFromContext(ctx)
+	switch {
+	case user == nil:
+		return query.Where("status = ?", dbmodel.PostStatus_Published)
+	case user.Type == dbmodel.UserType_Admin:
+		return query
+	default:
+		return query.Where("status = ? OR author_id = ?", dbmodel.PostStatus_Published, user.ID)
+	}
+}
+
+// Same rules as `scope_visible_posts`, for a post that is already loaded.
+func post_visible_to(user *dbmodel.User, post *dbmodel.Post) bool {
+	switch {
+	case post.DeletedAt != nil:
+		// Deleted posts are only reachable through the unscoped lookups.
+		return false
+	case post.Status == dbmodel.PostStatus_Published:
+		return true
+	case user == nil:
+		return false
+	default:
+		return user.Type == dbmodel.UserType_Admin || user.ID == post.AuthorID
+	}
+}
+
+// Look up the post with the given `id`. A missing post, or one the
+// user of the request can not see, is reported as a NOT_FOUND GraphQL
+// error.
+func (r *Resolver) find_post(ctx context.Context, id int) (*dbmodel.Post, error) {
+	return find_post_in(scope_visible_posts(ctx, r.Database), id)
+}
+
+// Load the post with the given `id` through the loaders of the request,
+// with the same visibility rules as `find_post`. Only use this for reads;
+// mutations should work on a fresh copy from `find_post`.
+func (r *Resolver) load_post(ctx context.Context, id int) (*dbmodel.Post, error) {
+	post, err := r.loaders_for(ctx).PostByID.Load(ctx, uint64(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch post %d: %v", id, err)
+	}
+	if post == nil || !post_visible_to(gql_middleware.UserFromContext(ctx), post) {
+		return nil, err_not_found("post", id)
+	}
+	return post, nil
+}
+
+// Same as `find_post`, but soft deleted posts are found as well.
+func (r *Resolver) find_post_unscoped(ctx context.Context, id int) (*dbmodel.Post, error) {
+	return find_post_in(scope_visible_posts(ctx, r.Database.Unscoped()), id)
+}
+
+func find_post_in(db *gorm.DB, id int) (*dbmodel.Post, error) {
+	var post dbmodel.Post
+	err := db.First(&post, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, err_not_found("post", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch post %d: %v", id, err)
+	}
+	return &post, nil
+}
+
+// Move `post` to a new status, clearing its schedule.
+func (r *Resolver) set_post_status(post *dbmodel.Post, status dbmodel.PostStatus) error {
+	was_published := post.Status == dbmodel.PostStatus_Published
+	post.Status = status
+	post.ScheduledAt = nil
+	if status == dbmodel.PostStatus_Published && post.PublishedAt == nil {
+		now := time.Now()
+		post.PublishedAt = &now
+	}
+	if err := r.Database.Save(post).Error; err != nil {
+		return fmt.Errorf("failed to update status of post %d: %v", post.ID, err)
+	}
+
+	if status == dbmodel.PostStatus_Published && !was_published {
+		r.Events.PostPublished(post)
+	} else {
+		r.Events.PostUpdated(post)
+	}
+	return nil
+}
+
+// Associations of a post to replace when it is saved. Nil fields keep
+// the current associations.
+type post_relations struct {
+	tags       *[]dbmodel.Tag
+	categories *[]dbmodel.Category
+}
+
+// Resolve the tags and categories named in `input`.
+func (r *Resolver) post_relations_from_input(input *model.NewPost) (*post_relations, error) {
+	relations := post_relations{}
+	if input.Tags != nil {
+		tags, err := r.find_or_create_tags(input.Tags)
+		if err != nil {
+			return nil, err
+		}
+		relations.tags = &tags
+	}
+	if input.Categories != nil {
+		categories, err := r.find_categories_by_slug(input.Categories)
+		if err != nil {
+			return nil, err
+		}
+		relations.categories = &categories
+	}
+	return &relations, nil
+}
+
+func (relations *post_relations) save(tx *gorm.DB, post *dbmodel.Post) error {
+	if relations == nil {
+		return nil
+	}
+	if relations.tags != nil {
+		if err := replace_association(tx, post, "Tags", *relations.tags); err != nil {
+			return err
+		}
+		post.Tags = *relations.tags
+	}
+	if relations.categories != nil {
+		if err := replace_association(tx, post, "Categories", *relations.categories); err != nil {
+			return err
+		}
+		post.Categories = *relations.categories
+	}
+	return nil
+}
+
+func replace_association[T any](tx *gorm.DB, post *dbmodel.Post, name string, values []T) error {
+	association := tx.Model(post).Association(name)
+	if len(values) == 0 {
+		return association.Clear().Error
+	}
+	return association.Replace(values).Error
+}
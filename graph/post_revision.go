@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util/diff"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Names of the post fields tracked by revisions, as listed in
+// `PostRevision.changedFields`.
+const (
+	_revision_field_title   = "title"
+	_revision_field_content = "content"
+	_revision_field_hero    = "hero"
+)
+
+func post_revision_to_model(revision *dbmodel.PostRevision) *model.PostRevision {
+	changed_fields := []string{}
+	if len(revision.ChangedFields) > 0 {
+		changed_fields = strings.Split(revision.ChangedFields, ",")
+	}
+	return &model.PostRevision{
+		Revision:      revision.Revision,
+		Title:         revision.Title,
+		Content:       revision.Content,
+		Hero:          revision.Hero,
+		ChangedFields: changed_fields,
+		RevertedFrom:  revision.RevertedFrom,
+		CreatedAt:     revision.CreatedAt,
+		PostID:        revision.PostID,
+		EditorID:      revision.EditorID,
+	}
+}
+
+// Same as `post_comments_page`, for the revisions of the post `post_id`.
+func (r *Resolver) post_revisions_page(ctx context.Context, post_id uint64, window *page_window) (*page[dbmodel.PostRevision], error) {
+	key := func(revision *dbmodel.PostRevision) uint64 { return revision.ID }
+	order := &page_order[dbmodel.PostRevision]{}
+	if window.is_first_page() {
+		first, err := r.loaders_for(ctx).RevisionsByPostID.Load(ctx, first_page_key{post_id: post_id, limit: window.limit})
+		if err != nil {
+			return nil, err
+		}
+		return new_page(first.Rows, first.TotalCount, window, key, order), nil
+	}
+
+	query := r.Database.Model(&dbmodel.PostRevision{}).Where("post_id = ?", post_id)
+	return paginate(query, window, "id", key, order)
+}
+
+func post_revision_page_to_connection(page *page[dbmodel.PostRevision]) *model.PostRevisionConnection {
+	edges := make([]*model.PostRevisionEdge, 0, len(page.Rows))
+	for i := range page.Rows {
+		edges = append(edges, &model.PostRevisionEdge{
+			Cursor: page.Cursors[i],
+			Node:   post_revision_to_model(&page.Rows[i]),
+		})
+	}
+	return &model.PostRevisionConnection{
+		Edges:      edges,
+		PageInfo:   page.PageInfo,
+		TotalCount: page.TotalCount,
+	}
+}
+
+// List the tracked fields that differ between two versions of a post.
+func changed_post_fields(before *dbmodel.Post, after *dbmodel.Post) []string {
+	changed := []string{}
+	if before == nil || before.Title != after.Title {
+		changed = append(changed, _revision_field_title)
+	}
+	if before == nil || before.Content != after.Content {
+		changed = append(changed, _revision_field_content)
+	}
+	if before == nil || before.Hero != after.Hero {
+		changed = append(changed, _revision_field_hero)
+	}
+	return changed
+}
+
+func new_post_revision(post *dbmodel.Post, number int, editor *dbmodel.User, changed []string) *dbmodel.PostRevision {
+	revision := dbmodel.PostRevision{
+		PostID:        post.ID,
+		Revision:      number,
+		Title:         post.Title,
+		Content:       post.Content,
+		Hero:          post.Hero,
+		ChangedFields: strings.Join(changed, ","),
+	}
+	if editor != nil {
+		revision.EditorID = &editor.ID
+	}
+	return &revision
+}
+
+// Save `post` along with its `relations` and record its new state as a
+// revision made by `editor`, in a single transaction. `previous` is the
+// state of the post before the edit, or nil when the post is being
+// created. No revision is written if none of the tracked fields changed.
+func (r *Resolver) save_post_with_revision(post *dbmodel.Post, previous *dbmodel.Post, editor *dbmodel.User, reverted_from *int, relations *post_relations) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(post).Error; err != nil {
+			return err
+		}
+		if err := relations.save(tx, post); err != nil {
+			return err
+		}
+
+		changed := changed_post_fields(previous, post)
+		if len(changed) == 0 && reverted_from == nil {
+			return nil
+		}
+
+		var latest int
+		revisions_table := tx.NewScope(&dbmodel.PostRevision{}).TableName()
+		err := tx.Raw(fmt.Sprintf(
+			"SELECT COALESCE(MAX(revision), 0) FROM %s WHERE post_id = ? FOR UPDATE", revisions_table),
+			post.ID).Row().Scan(&latest)
+		if err != nil {
+			return err
+		}
+
+		if latest == 0 && previous != nil {
+			// The post predates revision tracking; keep a snapshot of the
+			// version being replaced so it is not lost.
+			latest = 1
+			baseline := new_post_revision(previous, latest, nil, nil)
+			if err := tx.Create(baseline).Error; err != nil {
+				return err
+			}
+		}
+
+		revision := new_post_revision(post, latest+1, editor, changed)
+		revision.RevertedFrom = reverted_from
+		return tx.Create(revision).Error
+	})
+}
+
+// Look up revision number `number` of the post `post_id`.
+func (r *Resolver) find_post_revision(post_id uint64, number int) (*dbmodel.PostRevision, error) {
+	var revision dbmodel.PostRevision
+	err := r.Database.Where("post_id = ? AND revision = ?", post_id, number).First(&revision).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, new_coded_error(ErrCode_NotFound, "revision %d of post %d not found", number, post_id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revision %d of post %d: %v", number, post_id, err)
+	}
+	return &revision, nil
+}
+
+var _diff_operation_to_model = map[diff.Operation]model.DiffOperation{
+	diff.Operation_Equal:  model.DiffOperationEqual,
+	diff.Operation_Insert: model.DiffOperationInsert,
+	diff.Operation_Delete: model.DiffOperationDelete,
+}
+
+// Compare the content of two revisions of a post.
+func (r *Resolver) diff_post_revisions(ctx context.Context, post_id int, from int, to int) (*model.PostRevisionDiff, error) {
+	post, err := r.find_post(ctx, post_id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_view_history, post); err != nil {
+		return nil, err
+	}
+	from_revision, err := r.find_post_revision(post.ID, from)
+	if err != nil {
+		return nil, err
+	}
+	to_revision, err := r.find_post_revision(post.ID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := diff.Lines(from_revision.Content, to_revision.Content)
+	result := model.PostRevisionDiff{
+		PostID:       post_id,
+		FromRevision: from,
+		ToRevision:   to,
+		TitleChanged: from_revision.Title != to_revision.Title,
+		Lines:        make([]*model.DiffLine, 0, len(lines)),
+	}
+	for _, line := range lines {
+		result.Lines = append(result.Lines, &model.DiffLine{
+			Operation: _diff_operation_to_model[line.Operation],
+			Text:      line.Text,
+		})
+	}
+	return &result, nil
+}
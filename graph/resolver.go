@@ -8,4 +8,6 @@ import "github.com/jinzhu/gorm"
 
 type Resolver struct {
 	Database *gorm.DB
+	// Feeds the GraphQL subscriptions.
+	Events *Events
 }
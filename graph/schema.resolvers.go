@@ -6,35 +6,687 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"go-graphql-api/auth"
+	"go-graphql-api/dbmodel"
 	"go-graphql-api/graph/model"
+	"go-graphql-api/jobs"
+	"go-graphql-api/util"
+	"go-graphql-api/util/gql_middleware"
+	"go-graphql-api/util/logger"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
 )
 
+// Author is the resolver for the author field.
+func (r *commentResolver) Author(ctx context.Context, obj *model.Comment) (*model.User, error) {
+	user, err := r.load_user(ctx, obj.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return public_user_to_model(user), nil
+}
+
 // CreatePost is the resolver for the CreatePost field.
 func (r *mutationResolver) CreatePost(ctx context.Context, input model.NewPost) (*model.Post, error) {
-	panic(fmt.Errorf("not implemented: CreatePost - CreatePost"))
+	author, err := require_user(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Posts start out as drafts until they get published.
+	post := dbmodel.Post{AuthorID: author.ID, Status: dbmodel.PostStatus_Draft}
+	if err := apply_post_input(&post, &input); err != nil {
+		return nil, err
+	}
+	relations, err := r.post_relations_from_input(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.save_post_with_revision(&post, nil, author, nil, relations); err != nil {
+		return nil, fmt.Errorf("failed to create post: %v", err)
+	}
+	return post_to_model(&post), nil
 }
 
 // UpdatePost is the resolver for the UpdatePost field.
 func (r *mutationResolver) UpdatePost(ctx context.Context, postID int, input *model.NewPost) (*model.Post, error) {
-	panic(fmt.Errorf("not implemented: UpdatePost - UpdatePost"))
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_update, post); err != nil {
+		return nil, err
+	}
+	if input == nil {
+		// Nothing to update.
+		return post_to_model(post), nil
+	}
+
+	previous := *post
+	if err := apply_post_input(post, input); err != nil {
+		return nil, err
+	}
+	relations, err := r.post_relations_from_input(input)
+	if err != nil {
+		return nil, err
+	}
+
+	editor := gql_middleware.UserFromContext(ctx)
+	if err := r.save_post_with_revision(post, &previous, editor, nil, relations); err != nil {
+		return nil, fmt.Errorf("failed to update post %d: %v", postID, err)
+	}
+	r.Events.PostUpdated(post)
+	return post_to_model(post), nil
+}
+
+// RevertPost is the resolver for the RevertPost field.
+func (r *mutationResolver) RevertPost(ctx context.Context, postID int, revision int) (*model.Post, error) {
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_update, post); err != nil {
+		return nil, err
+	}
+	target, err := r.find_post_revision(post.ID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := *post
+	post.Title = target.Title
+	post.Content = target.Content
+	post.Hero = target.Hero
+
+	editor := gql_middleware.UserFromContext(ctx)
+	if err := r.save_post_with_revision(post, &previous, editor, &revision, nil); err != nil {
+		return nil, fmt.Errorf("failed to revert post %d to revision %d: %v", postID, revision, err)
+	}
+	r.Events.PostUpdated(post)
+	return post_to_model(post), nil
+}
+
+// PublishPost is the resolver for the PublishPost field.
+func (r *mutationResolver) PublishPost(ctx context.Context, postID int) (*model.Post, error) {
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_publish, post); err != nil {
+		return nil, err
+	}
+	if err := r.set_post_status(post, dbmodel.PostStatus_Published); err != nil {
+		return nil, err
+	}
+	return post_to_model(post), nil
+}
+
+// UnpublishPost is the resolver for the UnpublishPost field.
+func (r *mutationResolver) UnpublishPost(ctx context.Context, postID int, archive *bool) (*model.Post, error) {
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_publish, post); err != nil {
+		return nil, err
+	}
+
+	status := dbmodel.PostStatus_Draft
+	if archive != nil && *archive {
+		status = dbmodel.PostStatus_Archived
+	}
+	if err := r.set_post_status(post, status); err != nil {
+		return nil, err
+	}
+	return post_to_model(post), nil
+}
+
+// SchedulePost is the resolver for the SchedulePost field.
+func (r *mutationResolver) SchedulePost(ctx context.Context, postID int, publishAt time.Time) (*model.Post, error) {
+	if !publishAt.After(time.Now()) {
+		return nil, fmt.Errorf("publishAt must be in the future")
+	}
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_publish, post); err != nil {
+		return nil, err
+	}
+	if post.Status == dbmodel.PostStatus_Published {
+		return nil, fmt.Errorf("post %d is already published", postID)
+	}
+
+	post.Status = dbmodel.PostStatus_Scheduled
+	post.ScheduledAt = &publishAt
+	if err := r.Database.Save(post).Error; err != nil {
+		return nil, fmt.Errorf("failed to schedule post %d: %v", postID, err)
+	}
+	r.Events.PostUpdated(post)
+	return post_to_model(post), nil
+}
+
+// AddComment is the resolver for the AddComment field.
+func (r *mutationResolver) AddComment(ctx context.Context, postID int, parentID *int, body string) (*model.Comment, error) {
+	author, err := require_user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, err = validate_comment_body(body)
+	if err != nil {
+		return nil, err
+	}
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := dbmodel.Comment{PostID: post.ID, AuthorID: author.ID, Body: body}
+	if parentID != nil {
+		parent, err := r.find_comment(*parentID)
+		if err != nil {
+			return nil, err
+		}
+		if parent.PostID != post.ID {
+			return nil, fmt.Errorf("comment %d does not belong to post %d", *parentID, postID)
+		}
+		comment.ParentID = &parent.ID
+	}
+
+	if err := r.Database.Create(&comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to add comment: %v", err)
+	}
+	r.Events.CommentAdded(&comment)
+	return comment_to_model(&comment), nil
+}
+
+// EditComment is the resolver for the EditComment field.
+func (r *mutationResolver) EditComment(ctx context.Context, id int, body string) (*model.Comment, error) {
+	body, err := validate_comment_body(body)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := r.find_comment(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_comment(ctx, action_update, comment); err != nil {
+		return nil, err
+	}
+
+	comment.Body = body
+	if err := r.Database.Save(comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to edit comment %d: %v", id, err)
+	}
+	return comment_to_model(comment), nil
+}
+
+// DeleteComment is the resolver for the DeleteComment field.
+func (r *mutationResolver) DeleteComment(ctx context.Context, id int) (bool, error) {
+	comment, err := r.find_comment(id)
+	if err != nil {
+		return false, err
+	}
+	if err := authorize_comment(ctx, action_delete, comment); err != nil {
+		return false, err
+	}
+
+	if err := r.delete_comment_thread(comment); err != nil {
+		return false, fmt.Errorf("failed to delete comment %d: %v", id, err)
+	}
+	return true, nil
+}
+
+// CreateTag is the resolver for the CreateTag field.
+func (r *mutationResolver) CreateTag(ctx context.Context, name string) (*model.Tag, error) {
+	tags, err := r.find_or_create_tags([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	return tag_to_model(&tags[0]), nil
+}
+
+// RenameTag is the resolver for the RenameTag field.
+func (r *mutationResolver) RenameTag(ctx context.Context, id int, name string) (*model.Tag, error) {
+	slug, err := validate_taxonomy_name(name)
+	if err != nil {
+		return nil, err
+	}
+	tag, err := r.find_tag(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensure_slug_available(&dbmodel.Tag{}, slug, tag.ID); err != nil {
+		return nil, err
+	}
+
+	tag.Name = strings.TrimSpace(name)
+	tag.Slug = slug
+	if err := r.Database.Save(tag).Error; err != nil {
+		return nil, fmt.Errorf("failed to rename tag %d: %v", id, err)
+	}
+	return tag_to_model(tag), nil
+}
+
+// MergeTags is the resolver for the MergeTags field.
+func (r *mutationResolver) MergeTags(ctx context.Context, sourceIds []int, targetID int) (*model.Tag, error) {
+	target, err := r.find_tag(targetID)
+	if err != nil {
+		return nil, err
+	}
+	for _, source_id := range sourceIds {
+		if _, err := r.find_tag(source_id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.merge_tags(sourceIds, target); err != nil {
+		return nil, fmt.Errorf("failed to merge tags into tag %d: %v", targetID, err)
+	}
+	return tag_to_model(target), nil
+}
+
+// CreateCategory is the resolver for the CreateCategory field.
+func (r *mutationResolver) CreateCategory(ctx context.Context, name string) (*model.Category, error) {
+	slug, err := validate_taxonomy_name(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensure_slug_available(&dbmodel.Category{}, slug, 0); err != nil {
+		return nil, err
+	}
+
+	category := dbmodel.Category{Name: strings.TrimSpace(name), Slug: slug}
+	if err := r.Database.Create(&category).Error; err != nil {
+		return nil, fmt.Errorf("failed to create category: %v", err)
+	}
+	return category_to_model(&category), nil
+}
+
+// RenameCategory is the resolver for the RenameCategory field.
+func (r *mutationResolver) RenameCategory(ctx context.Context, id int, name string) (*model.Category, error) {
+	slug, err := validate_taxonomy_name(name)
+	if err != nil {
+		return nil, err
+	}
+	category, err := r.find_category(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.ensure_slug_available(&dbmodel.Category{}, slug, category.ID); err != nil {
+		return nil, err
+	}
+
+	category.Name = strings.TrimSpace(name)
+	category.Slug = slug
+	if err := r.Database.Save(category).Error; err != nil {
+		return nil, fmt.Errorf("failed to rename category %d: %v", id, err)
+	}
+	return category_to_model(category), nil
+}
+
+// DeletePost is the resolver for the DeletePost field.
+func (r *mutationResolver) DeletePost(ctx context.Context, postID int) (*model.Post, error) {
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_delete, post); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := r.Database.Model(post).UpdateColumn("deleted_at", now).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete post %d: %v", postID, err)
+	}
+	post.DeletedAt = &now
+	// Ends the subscriptions to the post.
+	r.Events.PostUpdated(post)
+	return post_to_model(post), nil
+}
+
+// RestorePost is the resolver for the RestorePost field.
+func (r *mutationResolver) RestorePost(ctx context.Context, postID int) (*model.Post, error) {
+	post, err := r.find_post_unscoped(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorize_post(ctx, action_restore, post); err != nil {
+		return nil, err
+	}
+	if post.DeletedAt == nil {
+		// Not deleted, nothing to restore.
+		return post_to_model(post), nil
+	}
+	if time.Since(*post.DeletedAt) > util.PostRetentionPeriod() {
+		return nil, fmt.Errorf("post %d was deleted more than %v ago and can no longer be restored", postID, util.PostRetentionPeriod())
+	}
+
+	err = r.Database.Unscoped().Model(post).UpdateColumn("deleted_at", gorm.Expr("NULL")).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore post %d: %v", postID, err)
+	}
+	post.DeletedAt = nil
+	return post_to_model(post), nil
+}
+
+// PurgePost is the resolver for the PurgePost field.
+func (r *mutationResolver) PurgePost(ctx context.Context, postID int) (bool, error) {
+	post, err := r.find_post_unscoped(ctx, postID)
+	if err != nil {
+		return false, err
+	}
+	if err := authorize_post(ctx, action_purge, post); err != nil {
+		return false, err
+	}
+
+	if err := jobs.PurgePosts(r.Database, []uint64{post.ID}); err != nil {
+		return false, fmt.Errorf("failed to purge post %d: %v", postID, err)
+	}
+	return true, nil
+}
+
+// UpdateProfile is the resolver for the updateProfile field.
+func (r *mutationResolver) UpdateProfile(ctx context.Context, input model.UpdateProfileInput) (*model.User, error) {
+	user, err := require_user(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.DisplayName != nil {
+		display_name, err := validate_display_name(*input.DisplayName)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.Database.Model(user).UpdateColumn("display_name", display_name).Error; err != nil {
+			return nil, fmt.Errorf("failed to update profile of user %d: %v", user.ID, err)
+		}
+		user.DisplayName = display_name
+	}
+	return user_to_model(ctx, user), nil
+}
+
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, token string) (*model.AuthSession, error) {
+	session, err := auth.RefreshSession(r.Database, token)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return nil, err_invalid_refresh_token()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh session: %v", err)
+	}
+	return session_to_model(session), nil
+}
+
+// Logout is the resolver for the logout field.
+func (r *mutationResolver) Logout(ctx context.Context) (bool, error) {
+	if err := auth.RevokeSession(gql_middleware.ClaimsFromContext(ctx)); err != nil {
+		return false, fmt.Errorf("failed to log out: %v", err)
+	}
+	return true, nil
+}
+
+// LogoutAllSessions is the resolver for the logoutAllSessions field.
+func (r *mutationResolver) LogoutAllSessions(ctx context.Context) (bool, error) {
+	user, err := require_user(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := auth.RevokeUserTokens(user.ID); err != nil {
+		return false, fmt.Errorf("failed to log out of all sessions: %v", err)
+	}
+	return true, nil
+}
+
+// RevokeUserTokens is the resolver for the revokeUserTokens field.
+func (r *mutationResolver) RevokeUserTokens(ctx context.Context, userID int) (bool, error) {
+	user, err := r.load_user(ctx, uint64(userID))
+	if err != nil {
+		return false, err
+	}
+	if err := auth.RevokeUserTokens(user.ID); err != nil {
+		return false, err
+	}
+	logger.Info("Admin %d revoked every token of user %d", gql_middleware.UserFromContext(ctx).ID, user.ID)
+	return true, nil
+}
+
+// Author is the resolver for the author field.
+func (r *postResolver) Author(ctx context.Context, obj *model.Post) (*model.User, error) {
+	user, err := r.load_user(ctx, obj.AuthorID)
+	if err != nil {
+		return nil, err
+	}
+	return public_user_to_model(user), nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *postResolver) Tags(ctx context.Context, obj *model.Post) ([]*model.Tag, error) {
+	tags, err := r.loaders_for(ctx).TagsByPostID.Load(ctx, uint64(obj.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tags of post %d: %v", obj.ID, err)
+	}
+	return tags_to_model(tags), nil
+}
+
+// Categories is the resolver for the categories field.
+func (r *postResolver) Categories(ctx context.Context, obj *model.Post) ([]*model.Category, error) {
+	categories, err := r.loaders_for(ctx).CategoriesByPostID.Load(ctx, uint64(obj.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch categories of post %d: %v", obj.ID, err)
+	}
+	return categories_to_model(categories), nil
+}
+
+// Comments is the resolver for the comments field.
+func (r *postResolver) Comments(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string, depth *int) (*model.CommentConnection, error) {
+	window, err := new_page_window(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := r.post_comments_page(ctx, uint64(obj.ID), window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch comments of post %d: %v", obj.ID, err)
+	}
+
+	connection := model.CommentConnection{
+		Edges:      make([]*model.CommentEdge, 0, len(page.Rows)),
+		PageInfo:   page.PageInfo,
+		TotalCount: page.TotalCount,
+	}
+	roots := make([]*model.Comment, 0, len(page.Rows))
+	for i := range page.Rows {
+		comment := comment_to_model(&page.Rows[i])
+		roots = append(roots, comment)
+		connection.Edges = append(connection.Edges, &model.CommentEdge{Cursor: page.Cursors[i], Node: comment})
+	}
+	if err := r.load_comment_thread(ctx, roots, comment_thread_depth(depth)); err != nil {
+		return nil, fmt.Errorf("failed to fetch comment replies of post %d: %v", obj.ID, err)
+	}
+	return &connection, nil
+}
+
+// Revisions is the resolver for the revisions field.
+func (r *postResolver) Revisions(ctx context.Context, obj *model.Post, first *int, after *string, last *int, before *string) (*model.PostRevisionConnection, error) {
+	window, err := new_page_window(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	post := dbmodel.Post{ID: uint64(obj.ID), AuthorID: obj.AuthorID}
+	if err := authorize_post(ctx, action_view_history, &post); err != nil {
+		return nil, err
+	}
+
+	page, err := r.post_revisions_page(ctx, uint64(obj.ID), window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revisions of post %d: %v", obj.ID, err)
+	}
+	return post_revision_page_to_connection(page), nil
+}
+
+// Editor is the resolver for the editor field.
+func (r *postRevisionResolver) Editor(ctx context.Context, obj *model.PostRevision) (*model.User, error) {
+	if obj.EditorID == nil {
+		return nil, nil
+	}
+	user, err := r.load_user(ctx, *obj.EditorID)
+	if err != nil {
+		return nil, err
+	}
+	return public_user_to_model(user), nil
 }
 
 // GetAllPosts is the resolver for the GetAllPosts field.
 func (r *queryResolver) GetAllPosts(ctx context.Context) ([]*model.Post, error) {
-	panic(fmt.Errorf("not implemented: GetAllPosts - GetAllPosts"))
+	var posts []dbmodel.Post
+	if err := scope_visible_posts(ctx, r.Database).Order("id").Find(&posts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %v", err)
+	}
+	return posts_to_model(posts), nil
 }
 
 // GetOnePost is the resolver for the GetOnePost field.
 func (r *queryResolver) GetOnePost(ctx context.Context, id int) (*model.Post, error) {
-	panic(fmt.Errorf("not implemented: GetOnePost - GetOnePost"))
+	post, err := r.load_post(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return post_to_model(post), nil
 }
 
+// Posts is the resolver for the posts field.
+func (r *queryResolver) Posts(ctx context.Context, first *int, after *string, last *int, before *string, filter *model.PostFilter, orderBy *model.PostOrder) (*model.PostConnection, error) {
+	window, err := new_page_window(first, after, last, before)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter != nil && filter.IncludeDeleted != nil && *filter.IncludeDeleted {
+		if _, err := require_admin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	query, err := apply_post_filter(scope_visible_posts(ctx, r.Database.Model(&dbmodel.Post{})), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := paginate(query, window, "id",
+		func(post *dbmodel.Post) uint64 { return post.ID }, post_page_order(orderBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch posts: %v", err)
+	}
+	return post_page_to_connection(page), nil
+}
+
+// Tags is the resolver for the tags field.
+func (r *queryResolver) Tags(ctx context.Context) ([]*model.Tag, error) {
+	var tags []dbmodel.Tag
+	if err := r.Database.Order("name").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch tags: %v", err)
+	}
+	return tags_to_model(tags), nil
+}
+
+// Categories is the resolver for the categories field.
+func (r *queryResolver) Categories(ctx context.Context) ([]*model.Category, error) {
+	var categories []dbmodel.Category
+	if err := r.Database.Order("name").Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %v", err)
+	}
+	return categories_to_model(categories), nil
+}
+
+// PostRevisionDiff is the resolver for the postRevisionDiff field.
+func (r *queryResolver) PostRevisionDiff(ctx context.Context, postID int, fromRevision int, toRevision int) (*model.PostRevisionDiff, error) {
+	return r.diff_post_revisions(ctx, postID, fromRevision, toRevision)
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	user := gql_middleware.UserFromContext(ctx)
+	if user == nil {
+		return nil, nil
+	}
+	return user_to_model(ctx, user), nil
+}
+
+// PostPublished is the resolver for the postPublished field.
+func (r *subscriptionResolver) PostPublished(ctx context.Context) (<-chan *model.Post, error) {
+	events := r.Events.posts_published.Subscribe(ctx, _all_posts_topic)
+	return forward_post_events(ctx, events, event_skip), nil
+}
+
+// PostUpdated is the resolver for the postUpdated field.
+func (r *subscriptionResolver) PostUpdated(ctx context.Context, id int) (<-chan *model.Post, error) {
+	// Fail right away for posts the subscriber can not see.
+	post, err := r.find_post(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	events := r.Events.posts_updated.Subscribe(ctx, post_topic(post.ID))
+	return forward_post_events(ctx, events, event_end), nil
+}
+
+// CommentAdded is the resolver for the commentAdded field.
+func (r *subscriptionResolver) CommentAdded(ctx context.Context, postID int) (<-chan *model.Comment, error) {
+	post, err := r.find_post(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	events := r.Events.comments_added.Subscribe(ctx, post_topic(post.ID))
+	return r.forward_comment_events(ctx, post.ID, events), nil
+}
+
+// Providers is the resolver for the providers field.
+func (r *userResolver) Providers(ctx context.Context, obj *model.User) ([]*model.LinkedProvider, error) {
+	if !can_view_private_fields(ctx, uint64(obj.ID)) {
+		return nil, err_forbidden("linked providers are only visible to their user")
+	}
+
+	tokens, err := r.loaders_for(ctx).ProvidersByUserID.Load(ctx, uint64(obj.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch providers of user %d: %v", obj.ID, err)
+	}
+	providers := make([]*model.LinkedProvider, 0, len(tokens))
+	for i := range tokens {
+		providers = append(providers, linked_provider_to_model(&tokens[i]))
+	}
+	return providers, nil
+}
+
+// Comment returns CommentResolver implementation.
+func (r *Resolver) Comment() CommentResolver { return &commentResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Post returns PostResolver implementation.
+func (r *Resolver) Post() PostResolver { return &postResolver{r} }
+
+// PostRevision returns PostRevisionResolver implementation.
+func (r *Resolver) PostRevision() PostRevisionResolver { return &postRevisionResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type commentResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
+type postResolver struct{ *Resolver }
+type postRevisionResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
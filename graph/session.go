@@ -0,0 +1,21 @@
+package graph
+
+import (
+	"go-graphql-api/auth"
+	"go-graphql-api/graph/model"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func session_to_model(session *auth.Session) *model.AuthSession {
+	return &model.AuthSession{
+		AccessToken:           session.AccessToken,
+		AccessTokenExpiresAt:  session.AccessTokenExpiry,
+		RefreshToken:          session.RefreshToken,
+		RefreshTokenExpiresAt: session.RefreshTokenExpiry,
+	}
+}
+
+func err_invalid_refresh_token() *gqlerror.Error {
+	return new_coded_error(ErrCode_Unauthenticated, "invalid refresh token")
+}
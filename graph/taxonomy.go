@@ -0,0 +1,170 @@
+package graph
+
+import (
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+var _slug_separators = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Derive the URL friendly identifier of a tag or category from its name,
+// e.g. "Go & GraphQL" becomes "go-graphql".
+func slugify(name string) string {
+	return strings.Trim(_slug_separators.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func tag_to_model(tag *dbmodel.Tag) *model.Tag {
+	return &model.Tag{
+		ID:   int(tag.ID),
+		Name: tag.Name,
+		Slug: tag.Slug,
+	}
+}
+
+func tags_to_model(tags []dbmodel.Tag) []*model.Tag {
+	result := make([]*model.Tag, 0, len(tags))
+	for i := range tags {
+		result = append(result, tag_to_model(&tags[i]))
+	}
+	return result
+}
+
+func category_to_model(category *dbmodel.Category) *model.Category {
+	return &model.Category{
+		ID:   int(category.ID),
+		Name: category.Name,
+		Slug: category.Slug,
+	}
+}
+
+func categories_to_model(categories []dbmodel.Category) []*model.Category {
+	result := make([]*model.Category, 0, len(categories))
+	for i := range categories {
+		result = append(result, category_to_model(&categories[i]))
+	}
+	return result
+}
+
+// Check `name` can be used for a tag or category and return its slug.
+func validate_taxonomy_name(name string) (string, error) {
+	slug := slugify(name)
+	if len(slug) == 0 {
+		return "", fmt.Errorf("name %q must contain at least one letter or digit", name)
+	}
+	return slug, nil
+}
+
+// Find the tags with the given names, creating the ones that do not
+// exist yet. Names that map to the same slug are the same tag.
+func (r *Resolver) find_or_create_tags(names []string) ([]dbmodel.Tag, error) {
+	tags := []dbmodel.Tag{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		slug, err := validate_taxonomy_name(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		tag := dbmodel.Tag{Name: strings.TrimSpace(name), Slug: slug}
+		err = r.Database.Where(dbmodel.Tag{Slug: slug}).FirstOrCreate(&tag).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to find or create tag %q: %v", name, err)
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// Find the categories with the given slugs. Every category must exist.
+func (r *Resolver) find_categories_by_slug(slugs []string) ([]dbmodel.Category, error) {
+	categories := []dbmodel.Category{}
+	if len(slugs) == 0 {
+		return categories, nil
+	}
+	if err := r.Database.Where("slug IN (?)", slugs).Find(&categories).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch categories: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, category := range categories {
+		found[category.Slug] = true
+	}
+	for _, slug := range slugs {
+		if !found[slug] {
+			return nil, new_coded_error(ErrCode_NotFound, "category %q not found", slug)
+		}
+	}
+	return categories, nil
+}
+
+func (r *Resolver) find_tag(id int) (*dbmodel.Tag, error) {
+	var tag dbmodel.Tag
+	err := r.Database.First(&tag, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, err_not_found("tag", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tag %d: %v", id, err)
+	}
+	return &tag, nil
+}
+
+func (r *Resolver) find_category(id int) (*dbmodel.Category, error) {
+	var category dbmodel.Category
+	err := r.Database.First(&category, id).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, err_not_found("category", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch category %d: %v", id, err)
+	}
+	return &category, nil
+}
+
+// Fail if a record of `model_instance`'s table other than `id` already
+// uses `slug`.
+func (r *Resolver) ensure_slug_available(model_instance interface{}, slug string, id uint64) error {
+	var count int
+	err := r.Database.Model(model_instance).Where("slug = ? AND id <> ?", slug, id).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("the slug %q is already in use", slug)
+	}
+	return nil
+}
+
+// Move every post tagged with one of `source_ids` over to `target`, and
+// delete the source tags. `post_tags` is the join table declared on
+// `dbmodel.Post.Tags`.
+func (r *Resolver) merge_tags(source_ids []int, target *dbmodel.Tag) error {
+	return r.Database.Transaction(func(tx *gorm.DB) error {
+		for _, source_id := range source_ids {
+			if uint64(source_id) == target.ID {
+				continue
+			}
+			err := tx.Exec("INSERT IGNORE INTO post_tags (post_id, tag_id) SELECT post_id, ? FROM post_tags WHERE tag_id = ?",
+				target.ID, source_id).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", source_id).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&dbmodel.Tag{}, "id = ?", source_id).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
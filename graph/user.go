@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/graph/model"
+	"go-graphql-api/util/gql_middleware"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Convert a database user record into its GraphQL representation, as
+// shown to everyone, e.g. as the author of a post. Only the fields that
+// are safe to share are copied over.
+func public_user_to_model(user *dbmodel.User) *model.User {
+	result := &model.User{
+		ID:   int(user.ID),
+		Type: user_type_to_model(user.Type),
+	}
+	if len(user.DisplayName) > 0 {
+		display_name := user.DisplayName
+		result.DisplayName = &display_name
+	}
+	return result
+}
+
+// Same as `public_user_to_model`, along with the private fields when the
+// requester is the user or an admin.
+func user_to_model(ctx context.Context, user *dbmodel.User) *model.User {
+	result := public_user_to_model(user)
+	if can_view_private_fields(ctx, user.ID) {
+		email := user.Email
+		result.Email = &email
+	}
+	return result
+}
+
+// Whether the requester may see the private fields of the user `user_id`.
+func can_view_private_fields(ctx context.Context, user_id uint64) bool {
+	viewer := gql_middleware.UserFromContext(ctx)
+	return viewer != nil && (viewer.ID == user_id || viewer.Type == dbmodel.UserType_Admin)
+}
+
+func linked_provider_to_model(token *dbmodel.OAuthToken) *model.LinkedProvider {
+	return &model.LinkedProvider{
+		Provider:  token.Provider,
+		LastLogin: token.LastRefresh,
+	}
+}
+
+const _display_name_max_length = 100
+
+// Normalize a display name, rejecting names that are too long or
+// contain control characters.
+func validate_display_name(display_name string) (string, error) {
+	display_name = strings.TrimSpace(display_name)
+	if utf8.RuneCountInString(display_name) > _display_name_max_length {
+		return "", fmt.Errorf("displayName must be at most %d characters", _display_name_max_length)
+	}
+	if strings.IndexFunc(display_name, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("displayName must not contain control characters")
+	}
+	return display_name, nil
+}
+
+// Load the user with the given `id` through the loaders of the request.
+// A missing user is reported as a NOT_FOUND GraphQL error.
+func (r *Resolver) load_user(ctx context.Context, id uint64) (*dbmodel.User, error) {
+	user, err := r.loaders_for(ctx).UserByID.Load(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %d: %v", id, err)
+	}
+	if user == nil {
+		return nil, err_not_found("user", id)
+	}
+	return user, nil
+}
+
+var _user_type_to_model = map[dbmodel.UserType]model.UserType{
+	dbmodel.UserType_Normal: model.UserTypeNormal,
+	dbmodel.UserType_Admin:  model.UserTypeAdmin,
+}
+
+func user_type_to_model(user_type dbmodel.UserType) model.UserType {
+	if result, ok := _user_type_to_model[user_type]; ok {
+		return result
+	}
+	return model.UserTypeNormal
+}
+
+func user_type_from_model(user_type model.UserType) dbmodel.UserType {
+	for db_type, model_type := range _user_type_to_model {
+		if model_type == user_type {
+			return db_type
+		}
+	}
+	return dbmodel.UserType_Normal
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"go-graphql-api/util/logger"
+	"time"
+)
+
+// Run `task` in the background every `interval`, for as long as the
+// process is alive. Errors are logged and do not stop the job.
+func RunPeriodically(name string, interval time.Duration, task func() error) {
+	logger.Info("Starting background job [%s], running every %v", name, interval)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := task(); err != nil {
+				logger.Err("Background job [%s] failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
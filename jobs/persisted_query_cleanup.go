@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Remove the persisted queries that were not used for `ttl`. Clients
+// register them again the next time they send them.
+func PurgeUnusedPersistedQueries(db *gorm.DB, ttl time.Duration) error {
+	cutoff := time.Now().Add(-ttl)
+	result := db.Where("COALESCE(last_used_at, created_at) < ?", cutoff).Delete(&dbmodel.PersistedQuery{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("Purged %d persisted query(ies) unused since %v", result.RowsAffected, cutoff)
+	}
+	return nil
+}
+
+// Periodically purge the persisted queries unused for APQ_TTL_DAYS.
+func StartPersistedQueryCleanup(db *gorm.DB) {
+	ttl := time.Duration(util.EnvIntOrDefault("APQ_TTL_DAYS", 30)) * 24 * time.Hour
+	RunPeriodically("persisted-query-cleanup", time.Hour, func() error {
+		return PurgeUnusedPersistedQueries(db, ttl)
+	})
+}
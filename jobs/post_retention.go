@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Permanently remove the posts that were soft deleted longer than
+// `retention` ago.
+func PurgeExpiredPosts(db *gorm.DB, retention time.Duration) error {
+	cutoff := time.Now().Add(-retention)
+	var post_ids []uint64
+	err := db.Model(&dbmodel.Post{}).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Pluck("id", &post_ids).Error
+	if err != nil {
+		return err
+	}
+	if len(post_ids) == 0 {
+		return nil
+	}
+
+	if err := PurgePosts(db, post_ids); err != nil {
+		return err
+	}
+	logger.Info("Purged %d post(s) deleted before %v", len(post_ids), cutoff)
+	return nil
+}
+
+// Permanently remove the posts `post_ids`, along with their revisions,
+// comments and tag and category links. Only the author of a post has a
+// foreign key, the rows referencing the post have to be removed here.
+func PurgePosts(db *gorm.DB, post_ids []uint64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		children := []interface{}{&dbmodel.PostRevision{}, &dbmodel.Comment{}}
+		for _, model := range children {
+			if err := tx.Unscoped().Where("post_id IN (?)", post_ids).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, join_table := range []string{"post_tags", "post_categories"} {
+			if err := tx.Exec("DELETE FROM "+join_table+" WHERE post_id IN (?)", post_ids).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Where("id IN (?)", post_ids).Delete(&dbmodel.Post{}).Error
+	})
+}
+
+// Periodically purge the soft deleted posts that are past the
+// retention period.
+func StartPostRetentionJob(db *gorm.DB) {
+	retention := util.PostRetentionPeriod()
+	RunPeriodically("post-retention", time.Hour, func() error {
+		return PurgeExpiredPosts(db, retention)
+	})
+}
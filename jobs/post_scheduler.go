@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Publish the scheduled posts whose publication time has been reached.
+// `on_published` is called with every post that got published.
+func PublishScheduledPosts(db *gorm.DB, on_published func(*dbmodel.Post)) error {
+	var due []dbmodel.Post
+	err := db.Where("status = ? AND scheduled_at <= ?", dbmodel.PostStatus_Scheduled, time.Now()).
+		Find(&due).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range due {
+		post := &due[i]
+		if post.PublishedAt == nil {
+			post.PublishedAt = post.ScheduledAt
+		}
+		// Only flip posts that are still scheduled, in case the post was
+		// changed since it was fetched.
+		result := db.Model(&dbmodel.Post{}).
+			Where("id = ? AND status = ?", post.ID, dbmodel.PostStatus_Scheduled).
+			Updates(map[string]interface{}{
+				"status":       dbmodel.PostStatus_Published,
+				"published_at": post.PublishedAt,
+				"scheduled_at": gorm.Expr("NULL"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			logger.Info("Published scheduled post %d", post.ID)
+			post.Status = dbmodel.PostStatus_Published
+			post.ScheduledAt = nil
+			on_published(post)
+		}
+	}
+	return nil
+}
+
+// Periodically publish the scheduled posts that are due.
+func StartPostScheduler(db *gorm.DB, on_published func(*dbmodel.Post)) {
+	interval := time.Duration(util.EnvIntOrDefault("POST_SCHEDULER_INTERVAL_SECONDS", 60)) * time.Second
+	RunPeriodically("post-scheduler", interval, func() error {
+		return PublishScheduledPosts(db, on_published)
+	})
+}
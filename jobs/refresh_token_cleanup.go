@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/logger"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Remove the refresh tokens that expired, they can no longer be used.
+func PurgeExpiredRefreshTokens(db *gorm.DB) error {
+	now := time.Now()
+	result := db.Where("expires_at < ?", now).Delete(&dbmodel.RefreshToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("Purged %d refresh token(s) expired before %v", result.RowsAffected, now)
+	}
+	return nil
+}
+
+// Periodically purge the expired refresh tokens.
+func StartRefreshTokenCleanup(db *gorm.DB) {
+	RunPeriodically("refresh-token-cleanup", time.Hour, func() error {
+		return PurgeExpiredRefreshTokens(db)
+	})
+}
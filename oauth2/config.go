@@ -40,7 +40,7 @@ var (
 			ProviderId:     "google",
 			Version:        2,
 			UserFromToken:  google_access_token_to_user_payload,
-			OnAuthComplete: redirect_home,
+			OnAuthComplete: complete_login,
 
 			Oauth2: &oauth2.Config{
 				ClientID:     util.EnvOrDefault("GOOGLE_CLIENT_ID", ""),
@@ -52,13 +52,8 @@ var (
 			},
 		},
 	}
-	_oauth_state_key = util.EnvOrDefault("OAUTH_STATE_KEY", "")
 )
 
-func redirect_home(w http.ResponseWriter, r *http.Request) {
-	http.Redirect(w, r, util.ServerUri(), http.StatusOK)
-}
-
 func RegisterOauthRoutes(router *chi.Mux) {
 
 	handlefn_wrap := func(pattern string, h func(string, http.HandlerFunc), handlerfn func(http.ResponseWriter, *http.Request)) {
@@ -71,25 +66,15 @@ func RegisterOauthRoutes(router *chi.Mux) {
 		logger.Info("Registering Oauth: %s/v%d", cfg.ProviderName, cfg.Version)
 		cfg.Oauth2.RedirectURL = util.ServerUri() + path.Join(basepath, "callback")
 
-		handlefn_wrap(path.Join(basepath, "login"), router.Get, oauth2_login_initiator(cfg.Oauth2))
+		handlefn_wrap(path.Join(basepath, "login"), router.Get, oauth2_login_initiator(cfg))
 		handlefn_wrap(path.Join(basepath, "callback"), router.Get, placeholder_oauth_callback_handler)
 	}
 }
 
 func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request) {
-	state := r.URL.Query().Get("state")
-	if state != _oauth_state_key {
-		send_json(w, r,
-			http.StatusBadRequest,
-			map[string]interface{}{
-				"error": "Corrupted state",
-			})
-		return
-	}
-
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) < 4 {
-		send_json(w, r,
+		util.WriteJSON(w,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"error": "Invalid cllback",
@@ -102,7 +87,7 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 
 	var config *AuthConfig = find_provider_oauth2_config(provider)
 	if config == nil {
-		send_json(w, r,
+		util.WriteJSON(w,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"error": fmt.Sprintf(`auth not configured for "%s"`, provider),
@@ -110,11 +95,43 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	state, err := verify_login_state(r, provider)
+	clear_state_cookie(w, r.URL.Query().Get("state"))
+	if err != nil {
+		logger.Warn("Rejected oauth callback from provider %s: %v", provider, err)
+		util.WriteJSON(w,
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": "Corrupted state",
+			})
+		return
+	}
+
+	db, err := database.GetDbInstance()
+	if err != nil {
+		logger.Err("Failed to get database instance: %#v", err)
+		util.WriteJSON(w,
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": "Internal error",
+			})
+		return
+	}
+	if err := consume_state(db, state); err != nil {
+		logger.Warn("Rejected oauth callback from provider %s: %v", provider, err)
+		util.WriteJSON(w,
+			http.StatusBadRequest,
+			map[string]interface{}{
+				"error": "Corrupted state",
+			})
+		return
+	}
+
 	code := r.URL.Query().Get("code")
-	token, err := config.Oauth2.Exchange(context.Background(), code)
+	token, err := config.Oauth2.Exchange(context.Background(), code, oauth2.VerifierOption(state.Verifier))
 	if err != nil {
-		logger.Err("Failed to exchange code for token in oauth callback: provider=%s, code=%s, error=%v", provider, code, err)
-		send_json(w, r,
+		logger.Err("Failed to exchange code for token in oauth callback: provider=%s, error=%v", provider, err)
+		util.WriteJSON(w,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"error": "Token exchange failed",
@@ -124,8 +141,8 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 
 	user, err := config.UserFromToken(token.AccessToken)
 	if err != nil {
-		logger.Err("Error exchanging access token to user payload: %#v", err)
-		send_json(w, r,
+		logger.Err("Error exchanging access token to user payload: provider=%s, error=%v", provider, err)
+		util.WriteJSON(w,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"error": "User token translation failed",
@@ -136,7 +153,7 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 
 	if !valid_user_payload(user) {
 		logger.Err("Invalid user payload from token conversion.")
-		send_json(w, r,
+		util.WriteJSON(w,
 			http.StatusBadRequest,
 			map[string]interface{}{
 				"error": "User token translation failed",
@@ -145,16 +162,6 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Check if the user exists
-	db, err := database.GetDbInstance()
-	if err != nil {
-		logger.Err("Failed to get database instance: %#v", err)
-		send_json(w, r,
-			http.StatusBadRequest,
-			map[string]interface{}{
-				"error": "Internal error",
-			})
-		return
-	}
 	var existing_user dbmodel.User
 	db.Model(dbmodel.User{}).Where("email = ?", user.Email).First(&existing_user)
 	if len(existing_user.Email) == 0 {
@@ -163,7 +170,7 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 		result := db.Create(user)
 		if result.Error != nil {
 			logger.Err("Error creating new user from oauth instance: %#v", result.Error)
-			send_json(w, r,
+			util.WriteJSON(w,
 				http.StatusBadRequest,
 				map[string]interface{}{
 					"error": "Internal error",
@@ -183,16 +190,25 @@ func placeholder_oauth_callback_handler(w http.ResponseWriter, r *http.Request)
 	new_auth_token_record := dbmodel.OAuthToken{
 		Version:  version,
 		Provider: provider,
-		// TODO: Consider encrypting the token information
+		// Encrypted when saved, see dbmodel/oauth_token.go.
 		AccessToken:  token.AccessToken,
 		RefreshToken: token.RefreshToken,
 		Expiry:       token.Expiry,
 		LastRefresh:  time.Now(),
 		UserId:       existing_user.ID,
 	}
-	db.Create(&new_auth_token_record)
+	if err := db.Create(&new_auth_token_record).Error; err != nil {
+		logger.Err("Failed to store oauth token: provider=%s, error=%v", provider, err)
+		util.WriteJSON(w,
+			http.StatusInternalServerError,
+			map[string]interface{}{
+				"error": "Internal error",
+			})
+		return
+	}
 	// Save the user information in the reqeust context.
-	r.WithContext(context.WithValue(r.Context(), util.ContextKey_User, &existing_user))
+	ctx := context.WithValue(r.Context(), util.ContextKey_User, &existing_user)
+	r = r.WithContext(context.WithValue(ctx, return_to_context_key{}, state.ReturnTo))
 	config.OnAuthComplete(w, r)
 }
 
@@ -205,25 +221,63 @@ func find_provider_oauth2_config(providerid string) *AuthConfig {
 	return nil
 }
 
-func oauth2_login_initiator(c *oauth2.Config) func(http.ResponseWriter, *http.Request) {
+// Redirect to the provider, with a random state bound to the browser by
+// a signed cookie and a PKCE challenge. The optional `return_to` query
+// parameter is where the client is sent back to once logged in, and must
+// be on an origin of OAUTH_RETURN_TO_ALLOWLIST.
+func oauth2_login_initiator(cfg *AuthConfig) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		url := c.AuthCodeURL(_oauth_state_key) // TODO: generate random state.
+		return_to := _frontend_url
+		if requested := r.URL.Query().Get("return_to"); len(requested) > 0 {
+			validated, err := validate_return_to(requested)
+			if err != nil {
+				logger.Warn("Rejected oauth login: %v", err)
+				util.WriteJSON(w,
+					http.StatusBadRequest,
+					map[string]interface{}{
+						"error": "Invalid return_to",
+					})
+				return
+			}
+			return_to = validated
+		}
+
+		verifier := oauth2.GenerateVerifier()
+		state, err := new_login_state(cfg.ProviderId, verifier, return_to)
+		if err == nil {
+			err = set_state_cookie(w, state)
+		}
+		if err != nil {
+			logger.Err("Failed to initiate oauth login: %v", err)
+			util.WriteJSON(w,
+				http.StatusInternalServerError,
+				map[string]interface{}{
+					"error": "Internal error",
+				})
+			return
+		}
+
+		url := cfg.Oauth2.AuthCodeURL(state.State, oauth2.S256ChallengeOption(verifier))
 		http.Redirect(w, r, url, http.StatusSeeOther)
 	}
 }
 
-func send_json(w http.ResponseWriter, r *http.Request, statuscode int, json_data map[string]interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statuscode)
-	json.NewEncoder(w).Encode(json_data)
-}
-
 func google_access_token_to_user_payload(accesstoken string) (*dbmodel.User, error) {
-	google_exchange_url := fmt.Sprintf("https://www.googleapis.com/oauth2/v2/userinfo?access_token=%s", accesstoken)
-	res, err := http.Get(google_exchange_url)
+	// The token is sent in a header rather than in the query string, so it
+	// does not end up in the errors and logs mentioning the url.
+	req, err := http.NewRequest(http.MethodGet, "https://www.googleapis.com/oauth2/v2/userinfo", nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Authorization", "Bearer "+accesstoken)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google userinfo request failed with status %d", res.StatusCode)
+	}
 
 	var userdat map[string]interface{}
 	err = json.NewDecoder(res.Body).Decode(&userdat)
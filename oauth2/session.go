@@ -0,0 +1,82 @@
+package oauth
+
+import (
+	"fmt"
+	"go-graphql-api/auth"
+	"go-graphql-api/database"
+	"go-graphql-api/util"
+	"go-graphql-api/util/gql_middleware"
+	"go-graphql-api/util/logger"
+	"net/http"
+	"net/url"
+)
+
+// How the access token is handed to the client at the end of the login.
+const (
+	TokenDelivery_Cookie   = "cookie"
+	TokenDelivery_Fragment = "fragment"
+	TokenDelivery_Both     = "both"
+)
+
+var (
+	_frontend_url   = util.EnvOrDefault("FRONTEND_URL", util.ServerUri())
+	_token_delivery = util.EnvOrDefault("AUTH_TOKEN_DELIVERY", TokenDelivery_Cookie)
+)
+
+type return_to_context_key struct{}
+
+// Start a session for the user who just logged in and redirect to the
+// `return_to` URL of the login, or the frontend. Depending on
+// AUTH_TOKEN_DELIVERY, the tokens are stored in the session cookies,
+// and/or passed in the fragment of the redirect URL as `access_token`,
+// `token_type`, `expires_in` and `refresh_token`.
+func complete_login(w http.ResponseWriter, r *http.Request) {
+	user := gql_middleware.UserFromContext(r.Context())
+	if user == nil {
+		util.WriteJSON(w,
+			http.StatusInternalServerError,
+			map[string]interface{}{
+				"error": "Internal error",
+			})
+		return
+	}
+
+	db, err := database.GetDbInstance()
+	if err != nil {
+		logger.Err("Failed to get database instance: %#v", err)
+		util.WriteJSON(w,
+			http.StatusInternalServerError,
+			map[string]interface{}{
+				"error": "Internal error",
+			})
+		return
+	}
+	session, err := auth.StartSession(db, user)
+	if err != nil {
+		logger.Err("Failed to start session for user %d: %v", user.ID, err)
+		util.WriteJSON(w,
+			http.StatusInternalServerError,
+			map[string]interface{}{
+				"error": "Internal error",
+			})
+		return
+	}
+
+	redirect_url, ok := r.Context().Value(return_to_context_key{}).(string)
+	if !ok {
+		redirect_url = _frontend_url
+	}
+	if _token_delivery != TokenDelivery_Fragment {
+		auth.SetSessionCookies(w, session)
+	}
+	if _token_delivery == TokenDelivery_Fragment || _token_delivery == TokenDelivery_Both {
+		fragment := url.Values{
+			"access_token":  {session.AccessToken},
+			"token_type":    {"Bearer"},
+			"expires_in":    {fmt.Sprint(util.SecondsUntil(session.AccessTokenExpiry))},
+			"refresh_token": {session.RefreshToken},
+		}
+		redirect_url += "#" + fragment.Encode()
+	}
+	http.Redirect(w, r, redirect_url, http.StatusSeeOther)
+}
@@ -0,0 +1,211 @@
+package oauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"go-graphql-api/auth"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	// The state cookies are named after their state, so logins started in
+	// several tabs at once do not replace the cookies of one another.
+	_state_cookie_prefix = "oauth_state_"
+	_state_cookie_path   = "/oauth"
+)
+
+var (
+	_state_key       = load_state_key()
+	_state_ttl       = time.Duration(util.EnvIntOrDefault("OAUTH_STATE_TTL_SECONDS", 600)) * time.Second
+	_return_to_hosts = load_return_to_allowlist()
+)
+
+// Key signing the state cookies. Without OAUTH_STATE_KEY a random key is
+// used, which only works as long as a single instance serves the logins.
+func load_state_key() []byte {
+	key := util.EnvOrDefault("OAUTH_STATE_KEY", "")
+	if len(key) == 0 {
+		logger.Warn("OAUTH_STATE_KEY not defined, using a random key to sign the oauth state")
+		random := make([]byte, 32)
+		if _, err := rand.Read(random); err != nil {
+			panic(fmt.Errorf("failed to generate oauth state key: %v", err))
+		}
+		return random
+	}
+	return []byte(key)
+}
+
+// Origins, as `scheme://host[:port]`, the client can be sent back to
+// after the login. Defaults to the origin of FRONTEND_URL.
+func load_return_to_allowlist() map[string]bool {
+	allowlist := map[string]bool{}
+	for _, origin := range strings.Split(util.EnvOrDefault("OAUTH_RETURN_TO_ALLOWLIST", _frontend_url), ",") {
+		parsed, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || len(parsed.Scheme) == 0 || len(parsed.Host) == 0 {
+			logger.Err("Ignoring invalid origin in OAUTH_RETURN_TO_ALLOWLIST: %q", origin)
+			continue
+		}
+		allowlist[strings.ToLower(parsed.Scheme+"://"+parsed.Host)] = true
+	}
+	return allowlist
+}
+
+// Check that `return_to` points to an allowed origin. Paths are resolved
+// against FRONTEND_URL, and the fragment is dropped since the tokens may
+// be passed in it.
+func validate_return_to(return_to string) (string, error) {
+	base, err := url.Parse(_frontend_url)
+	if err != nil {
+		return "", fmt.Errorf("invalid FRONTEND_URL: %v", err)
+	}
+	target, err := base.Parse(return_to)
+	if err != nil {
+		return "", fmt.Errorf("invalid return_to: %v", err)
+	}
+	if target.User != nil || !_return_to_hosts[strings.ToLower(target.Scheme+"://"+target.Host)] {
+		return "", fmt.Errorf("return_to %q is not allowed", return_to)
+	}
+	target.Fragment = ""
+	return target.String(), nil
+}
+
+// Login attempt, stored in a signed cookie between the redirection to
+// the provider and the callback.
+type login_state struct {
+	State     string `json:"state"`
+	Verifier  string `json:"verifier"`
+	Provider  string `json:"provider"`
+	ReturnTo  string `json:"return_to"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func new_login_state(provider string, verifier string, return_to string) (*login_state, error) {
+	state := make([]byte, 32)
+	if _, err := rand.Read(state); err != nil {
+		return nil, fmt.Errorf("failed to generate oauth state: %v", err)
+	}
+	return &login_state{
+		State:     base64.RawURLEncoding.EncodeToString(state),
+		Verifier:  verifier,
+		Provider:  provider,
+		ReturnTo:  return_to,
+		ExpiresAt: time.Now().Add(_state_ttl).Unix(),
+	}, nil
+}
+
+func sign_state(payload []byte) []byte {
+	mac := hmac.New(sha256.New, _state_key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Name of the cookie holding the login with the given `state`. The
+// states are random, their first characters are enough to tell them apart.
+func state_cookie_name(state string) string {
+	if len(state) > 8 {
+		state = state[:8]
+	}
+	return _state_cookie_prefix + state
+}
+
+func set_state_cookie(w http.ResponseWriter, state *login_state) error {
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(sign_state(payload))
+	http.SetCookie(w, &http.Cookie{
+		Name:     state_cookie_name(state.State),
+		Value:    value,
+		Path:     _state_cookie_path,
+		MaxAge:   int(_state_ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		// Lax so the cookie is sent along with the redirection back from
+		// the provider.
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func clear_state_cookie(w http.ResponseWriter, state string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     state_cookie_name(state),
+		Path:     _state_cookie_path,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   auth.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Get the login state of the browser from its signed cookie, and check
+// that the callback is for the same login: the `state` parameter of the
+// callback must match, for the same provider, before the state expires.
+func verify_login_state(r *http.Request, provider string) (*login_state, error) {
+	cookie, err := r.Cookie(state_cookie_name(r.URL.Query().Get("state")))
+	if err != nil {
+		return nil, fmt.Errorf("missing state cookie")
+	}
+	encoded_payload, encoded_signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return nil, fmt.Errorf("malformed state cookie")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded_payload)
+	if err != nil {
+		return nil, fmt.Errorf("malformed state cookie")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encoded_signature)
+	if err != nil || !hmac.Equal(signature, sign_state(payload)) {
+		return nil, fmt.Errorf("invalid state cookie signature")
+	}
+
+	var state login_state
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, fmt.Errorf("malformed state cookie")
+	}
+	switch {
+	case time.Now().Unix() > state.ExpiresAt:
+		return nil, fmt.Errorf("state has expired")
+	case state.Provider != provider:
+		return nil, fmt.Errorf("state was issued for another provider")
+	case subtle.ConstantTimeCompare([]byte(state.State), []byte(r.URL.Query().Get("state"))) != 1:
+		return nil, fmt.Errorf("state does not match")
+	}
+	return &state, nil
+}
+
+// Record that `state` was used, failing if it already was. The states
+// that expired are dropped along the way, they are rejected anyway.
+func consume_state(db *gorm.DB, state *login_state) error {
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&dbmodel.UsedOAuthState{}).Error; err != nil {
+		logger.Err("Failed to purge expired oauth states: %v", err)
+	}
+
+	err := db.Create(&dbmodel.UsedOAuthState{
+		State:     state.State,
+		ExpiresAt: time.Unix(state.ExpiresAt, 0),
+	}).Error
+	if err != nil {
+		var count int
+		if db.Model(&dbmodel.UsedOAuthState{}).Where("state = ?", state.State).Count(&count).Error == nil && count > 0 {
+			return fmt.Errorf("state has already been used")
+		}
+		return fmt.Errorf("failed to record oauth state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"go-graphql-api/dbmodel"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// Start a login with `provider` and return its state along with the
+// cookie the browser got for it.
+func start_test_login(t *testing.T, provider string) (*login_state, *http.Cookie) {
+	state, err := new_login_state(provider, "verifier", "/")
+	if err != nil {
+		t.Fatalf("new_login_state() error = %v", err)
+	}
+	return state, state_cookie(t, state)
+}
+
+func state_cookie(t *testing.T, state *login_state) *http.Cookie {
+	w := httptest.NewRecorder()
+	if err := set_state_cookie(w, state); err != nil {
+		t.Fatalf("set_state_cookie() error = %v", err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, want 1", len(cookies))
+	}
+	return cookies[0]
+}
+
+// Callback from the provider for the login `state`, sent by a browser
+// holding `cookies`.
+func callback_request(state string, cookies ...*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/oauth/google/callback?code=code&state="+url.QueryEscape(state), nil)
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+func TestVerifyLoginState(t *testing.T) {
+	tests := []struct {
+		name string
+		// Callback of the login, and the error it gets or an empty string.
+		callback func(t *testing.T) *http.Request
+		want_err string
+	}{
+		{
+			name: "valid",
+			callback: func(t *testing.T) *http.Request {
+				state, cookie := start_test_login(t, "google")
+				return callback_request(state.State, cookie)
+			},
+		},
+		{
+			name: "missing cookie",
+			callback: func(t *testing.T) *http.Request {
+				state, _ := start_test_login(t, "google")
+				return callback_request(state.State)
+			},
+			want_err: "missing state cookie",
+		},
+		{
+			name: "bad signature",
+			callback: func(t *testing.T) *http.Request {
+				state, cookie := start_test_login(t, "google")
+				payload, _, _ := strings.Cut(cookie.Value, ".")
+				cookie.Value = payload + "." + base64.RawURLEncoding.EncodeToString([]byte("forged"))
+				return callback_request(state.State, cookie)
+			},
+			want_err: "invalid state cookie signature",
+		},
+		{
+			name: "tampered payload",
+			callback: func(t *testing.T) *http.Request {
+				state, cookie := start_test_login(t, "google")
+				_, signature, _ := strings.Cut(cookie.Value, ".")
+				state.ReturnTo = "https://attacker.example"
+				payload, _ := json.Marshal(state)
+				cookie.Value = base64.RawURLEncoding.EncodeToString(payload) + "." + signature
+				return callback_request(state.State, cookie)
+			},
+			want_err: "invalid state cookie signature",
+		},
+		{
+			name: "malformed cookie",
+			callback: func(t *testing.T) *http.Request {
+				state, cookie := start_test_login(t, "google")
+				cookie.Value = "garbage"
+				return callback_request(state.State, cookie)
+			},
+			want_err: "malformed state cookie",
+		},
+		{
+			name: "expired state",
+			callback: func(t *testing.T) *http.Request {
+				state, _ := start_test_login(t, "google")
+				state.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+				return callback_request(state.State, state_cookie(t, state))
+			},
+			want_err: "state has expired",
+		},
+		{
+			name: "provider mismatch",
+			callback: func(t *testing.T) *http.Request {
+				state, cookie := start_test_login(t, "github")
+				return callback_request(state.State, cookie)
+			},
+			want_err: "state was issued for another provider",
+		},
+		{
+			name: "state mismatch",
+			callback: func(t *testing.T) *http.Request {
+				// Same cookie name, since it is named after the start of
+				// the state.
+				state, cookie := start_test_login(t, "google")
+				return callback_request(state.State[:8]+"other", cookie)
+			},
+			want_err: "state does not match",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := verify_login_state(test.callback(t), "google")
+			if len(test.want_err) == 0 && err != nil {
+				t.Fatalf("verify_login_state() error = %v, want none", err)
+			}
+			if len(test.want_err) > 0 && (err == nil || err.Error() != test.want_err) {
+				t.Fatalf("verify_login_state() error = %v, want %q", err, test.want_err)
+			}
+		})
+	}
+}
+
+// Logins started in two tabs at once both complete, each callback only
+// clearing the cookie of its own login.
+func TestConcurrentLogins(t *testing.T) {
+	first, first_cookie := start_test_login(t, "google")
+	second, second_cookie := start_test_login(t, "google")
+	if first_cookie.Name == second_cookie.Name {
+		t.Fatalf("both logins use the cookie %q", first_cookie.Name)
+	}
+
+	for _, login := range []*login_state{second, first} {
+		r := callback_request(login.State, first_cookie, second_cookie)
+		state, err := verify_login_state(r, "google")
+		if err != nil {
+			t.Fatalf("verify_login_state() error = %v", err)
+		}
+		if state.State != login.State {
+			t.Errorf("got the login state %q, want %q", state.State, login.State)
+		}
+
+		w := httptest.NewRecorder()
+		clear_state_cookie(w, login.State)
+		cleared := w.Result().Cookies()
+		if len(cleared) != 1 || cleared[0].Name != state_cookie_name(login.State) || cleared[0].MaxAge >= 0 {
+			t.Errorf("cleared cookies %v, want only %q", cleared, state_cookie_name(login.State))
+		}
+	}
+}
+
+// A state can only be used once, even before it expires. The states are
+// recorded in an in-memory SQLite database standing in for MySQL.
+func TestConsumeStateReplay(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.AutoMigrate(&dbmodel.UsedOAuthState{}).Error; err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+
+	first, _ := start_test_login(t, "google")
+	second, _ := start_test_login(t, "google")
+
+	if err := consume_state(db, first); err != nil {
+		t.Fatalf("consume_state() error = %v", err)
+	}
+	if err := consume_state(db, first); err == nil || err.Error() != "state has already been used" {
+		t.Errorf("replayed consume_state() error = %v, want %q", err, "state has already been used")
+	}
+	if err := consume_state(db, second); err != nil {
+		t.Errorf("consume_state() of another login error = %v", err)
+	}
+}
@@ -2,16 +2,25 @@ package main
 
 import (
 	"fmt"
+	"go-graphql-api/auth"
 	"go-graphql-api/graph"
+	"go-graphql-api/jobs"
 	oauth "go-graphql-api/oauth2"
 	"go-graphql-api/util"
+	"go-graphql-api/util/envelope"
+	"go-graphql-api/util/gql_cache"
 	"go-graphql-api/util/gql_middleware"
 	"go-graphql-api/util/logger"
 	"net/http"
+	"time"
 
 	"go-graphql-api/database"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi"
 	"github.com/joho/godotenv"
@@ -25,22 +34,45 @@ func main() {
 		panic(fmt.Errorf("failed to setup environment: %v", err))
 	}
 
+	if err := envelope.LoadKeys(); err != nil {
+		panic(fmt.Errorf("failed to load token encryption keys: %v", err))
+	}
 	db, err := database.GetDbInstance()
 	if err != nil {
 		panic(fmt.Errorf("failed to instantiate database connection: %v", err))
 	}
 
+	if err := auth.LoadKeySet(); err != nil {
+		panic(fmt.Errorf("failed to load jwt keys: %v", err))
+	}
+	if err := auth.StartRevocationStore(db); err != nil {
+		panic(fmt.Errorf("failed to load revoked tokens: %v", err))
+	}
+
+	events := graph.NewEvents()
+	jobs.StartPostRetentionJob(db)
+	jobs.StartPostScheduler(db, events.PostPublished)
+	jobs.StartRefreshTokenCleanup(db)
+	jobs.StartPersistedQueryCleanup(db)
+
 	router := chi.NewRouter()
-	router.Use(gql_middleware.JwtAuthMiddleware())
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(
-		graph.Config{Resolvers: &graph.Resolver{
-			Database: db,
-		}}))
+	config := graph.Config{Resolvers: &graph.Resolver{
+		Database: db,
+		Events:   events,
+	}}
+	graph.ConfigureDirectives(&config.Directives)
+	graph.ConfigureComplexity(&config.Complexity)
+	trusted_documents, err := load_trusted_documents()
+	if err != nil {
+		panic(fmt.Errorf("failed to load trusted documents: %v", err))
+	}
+	srv := new_graphql_server(graph.NewExecutableSchema(config), gql_cache.PersistedQueryCache(db), trusted_documents)
 
 	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
-	router.Handle("/query", srv)
+	router.With(gql_middleware.JwtAuthMiddleware(), graph.LoadersMiddleware(db)).Handle("/query", srv)
 	oauth.RegisterOauthRoutes(router)
+	auth.RegisterAuthRoutes(router, db)
 
 	logger.Info("connect to %s/ for GraphQL playground", util.ServerUri())
 	err = http.ListenAndServe(":"+util.ServerPort(), router)
@@ -49,6 +81,64 @@ func main() {
 	}
 }
 
+// Same setup as `handler.NewDefaultServer`, with websocket connections
+// authenticated from their `connection_init` payload and limits on the
+// depth and complexity of the operations. Automatic persisted queries
+// are stored in `apq_cache`. When `trusted_documents` is set, only the
+// operations of its manifest are executed for non-admin users.
+func new_graphql_server(
+	es graphql.ExecutableSchema,
+	apq_cache graphql.Cache,
+	trusted_documents *gql_middleware.TrustedDocuments,
+) *handler.Server {
+	srv := handler.New(es)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              gql_middleware.WebsocketAuthInit,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New(1000))
+
+	srv.Use(extension.Introspection{})
+	srv.Use(gql_middleware.DepthLimit{})
+	srv.Use(gql_middleware.ComplexityLimit())
+	if trusted_documents != nil {
+		srv.Use(trusted_documents)
+	}
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: apq_cache,
+	})
+	// Caches persisting the documents once they are validated.
+	if persister, ok := apq_cache.(graphql.HandlerExtension); ok {
+		srv.Use(persister)
+	}
+
+	return srv
+}
+
+// Load the manifest of trusted documents named by the
+// TRUSTED_DOCUMENTS_MANIFEST env variable, and reload it whenever the
+// file changes. Returns nil when the allowlist mode is disabled.
+func load_trusted_documents() (*gql_middleware.TrustedDocuments, error) {
+	path := util.EnvOrDefault("TRUSTED_DOCUMENTS_MANIFEST", "")
+	if path == "" {
+		return nil, nil
+	}
+
+	trusted_documents, err := gql_middleware.NewTrustedDocuments(path)
+	if err != nil {
+		return nil, err
+	}
+	interval := time.Duration(util.EnvIntOrDefault("TRUSTED_DOCUMENTS_RELOAD_SECONDS", 10)) * time.Second
+	jobs.RunPeriodically("trusted documents reload", interval, trusted_documents.Reload)
+	return trusted_documents, nil
+}
+
 func setup_environment() error {
 	return godotenv.Load()
 }
@@ -1,5 +1,6 @@
 package util
 
 const (
-	ContextKey_User = "user"
+	ContextKey_User       = "user"
+	ContextKey_AuthClaims = "auth_claims"
 )
@@ -0,0 +1,147 @@
+package dataloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fetch the values of a batch of keys. Keys without a value can be left
+// out of the returned map; loading them yields the zero value.
+type BatchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// Batches and caches the lookups of values by key.
+//
+// Every key requested within `wait` of the first key of a batch is
+// fetched with a single call to the batch function, which turns the N+1
+// queries of resolving a list of objects into one query per batch.
+// Values are cached for the lifetime of the loader, so loaders should be
+// created per request.
+type Loader[K comparable, V any] struct {
+	fetch     BatchFunc[K, V]
+	wait      time.Duration
+	max_batch int
+
+	mu    sync.Mutex
+	cache map[K]*result[V]
+	batch *batch[K, V]
+}
+
+type result[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+type batch[K comparable, V any] struct {
+	keys    []K
+	results map[K]*result[V]
+}
+
+// Create a loader collecting keys for `wait` before fetching them, with
+// at most `max_batch` keys per call to `fetch`.
+func New[K comparable, V any](fetch BatchFunc[K, V], wait time.Duration, max_batch int) *Loader[K, V] {
+	return &Loader[K, V]{
+		fetch:     fetch,
+		wait:      wait,
+		max_batch: max_batch,
+		cache:     map[K]*result[V]{},
+	}
+}
+
+// Get the value of `key`, waiting for the batch it is part of.
+func (l *Loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	l.mu.Lock()
+	r, ok := l.cache[key]
+	if !ok {
+		r = &result[V]{done: make(chan struct{})}
+		l.cache[key] = r
+		l.enqueue(ctx, key, r)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.value, r.err
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+}
+
+// Get the values of `keys`, fetched in the same batch when they are not
+// cached yet.
+func (l *Loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, error) {
+	results := make([]*result[V], len(keys))
+	l.mu.Lock()
+	for i, key := range keys {
+		r, ok := l.cache[key]
+		if !ok {
+			r = &result[V]{done: make(chan struct{})}
+			l.cache[key] = r
+			l.enqueue(ctx, key, r)
+		}
+		results[i] = r
+	}
+	l.mu.Unlock()
+
+	values := make([]V, len(keys))
+	for i, r := range results {
+		select {
+		case <-r.done:
+			if r.err != nil {
+				return nil, r.err
+			}
+			values[i] = r.value
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return values, nil
+}
+
+// Must be called with `l.mu` held.
+func (l *Loader[K, V]) enqueue(ctx context.Context, key K, r *result[V]) {
+	if l.batch == nil {
+		b := &batch[K, V]{results: map[K]*result[V]{}}
+		l.batch = b
+		go func() {
+			time.Sleep(l.wait)
+			l.mu.Lock()
+			pending := l.batch == b
+			if pending {
+				l.batch = nil
+			}
+			l.mu.Unlock()
+			if pending {
+				l.dispatch(ctx, b)
+			}
+		}()
+	}
+
+	l.batch.keys = append(l.batch.keys, key)
+	l.batch.results[key] = r
+	if len(l.batch.keys) >= l.max_batch {
+		// Full batch; fetch it now instead of waiting for the timer.
+		b := l.batch
+		l.batch = nil
+		go l.dispatch(ctx, b)
+	}
+}
+
+func (l *Loader[K, V]) dispatch(ctx context.Context, b *batch[K, V]) {
+	values, err := l.fetch(ctx, b.keys)
+	if err != nil {
+		// Do not cache failures so they can be retried.
+		l.mu.Lock()
+		for _, key := range b.keys {
+			delete(l.cache, key)
+		}
+		l.mu.Unlock()
+	}
+	for key, r := range b.results {
+		r.value = values[key]
+		r.err = err
+		close(r.done)
+	}
+}
@@ -0,0 +1,101 @@
+package diff
+
+import "strings"
+
+type Operation int
+
+const (
+	Operation_Equal  Operation = 0
+	Operation_Insert Operation = 1
+	Operation_Delete Operation = 2
+)
+
+// A line of a diff, and whether it was kept, inserted or deleted.
+type Line struct {
+	Operation Operation
+	Text      string
+}
+
+// Compute the line-level diff turning `from` into `to`.
+func Lines(from string, to string) []Line {
+	return diff_lines(split_lines(from), split_lines(to))
+}
+
+func split_lines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+}
+
+// Myers' diff algorithm: find the shortest edit script between `a` and
+// `b` by exploring the edit graph one edit distance `d` at a time, then
+// backtrack through the saved frontiers to recover the edits.
+func diff_lines(a []string, b []string) []Line {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return []Line{}
+	}
+
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end of both inputs, emitting lines in reverse.
+	lines := make([]Line, 0, max)
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prev_k int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prev_k = k + 1
+		} else {
+			prev_k = k - 1
+		}
+		prev_x := v[offset+prev_k]
+		prev_y := prev_x - prev_k
+
+		for x > prev_x && y > prev_y {
+			x--
+			y--
+			lines = append(lines, Line{Operation: Operation_Equal, Text: a[x]})
+		}
+		if d > 0 {
+			if x == prev_x {
+				lines = append(lines, Line{Operation: Operation_Insert, Text: b[prev_y]})
+			} else {
+				lines = append(lines, Line{Operation: Operation_Delete, Text: a[prev_x]})
+			}
+		}
+		x, y = prev_x, prev_y
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
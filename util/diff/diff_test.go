@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func equal_line(text string) Line  { return Line{Operation: Operation_Equal, Text: text} }
+func insert_line(text string) Line { return Line{Operation: Operation_Insert, Text: text} }
+func delete_line(text string) Line { return Line{Operation: Operation_Delete, Text: text} }
+
+// Rebuild the two sides of the diff from its lines.
+func sides(lines []Line) (string, string) {
+	var from, to []string
+	for _, line := range lines {
+		if line.Operation != Operation_Insert {
+			from = append(from, line.Text)
+		}
+		if line.Operation != Operation_Delete {
+			to = append(to, line.Text)
+		}
+	}
+	return strings.Join(from, "\n"), strings.Join(to, "\n")
+}
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []Line
+	}{
+		{name: "both empty", from: "", to: "", want: []Line{}},
+		{name: "identical", from: "a\nb", to: "a\nb", want: []Line{equal_line("a"), equal_line("b")}},
+		{name: "from empty", from: "", to: "a\nb", want: []Line{insert_line("a"), insert_line("b")}},
+		{name: "to empty", from: "a\nb", to: "", want: []Line{delete_line("a"), delete_line("b")}},
+		{name: "insertion at the start", from: "b\nc", to: "a\nb\nc", want: []Line{insert_line("a"), equal_line("b"), equal_line("c")}},
+		{name: "insertion in the middle", from: "a\nc", to: "a\nb\nc", want: []Line{equal_line("a"), insert_line("b"), equal_line("c")}},
+		{name: "insertion at the end", from: "a\nb", to: "a\nb\nc", want: []Line{equal_line("a"), equal_line("b"), insert_line("c")}},
+		{name: "deletion at the start", from: "a\nb\nc", to: "b\nc", want: []Line{delete_line("a"), equal_line("b"), equal_line("c")}},
+		{name: "deletion in the middle", from: "a\nb\nc", to: "a\nc", want: []Line{equal_line("a"), delete_line("b"), equal_line("c")}},
+		{name: "deletion at the end", from: "a\nb\nc", to: "a\nb", want: []Line{equal_line("a"), equal_line("b"), delete_line("c")}},
+		{name: "replacement", from: "a\nb\nc", to: "a\nx\nc", want: []Line{equal_line("a"), delete_line("b"), insert_line("x"), equal_line("c")}},
+		{name: "trailing newline added", from: "a", to: "a\n", want: []Line{equal_line("a"), insert_line("")}},
+		{name: "trailing newline removed", from: "a\n", to: "a", want: []Line{equal_line("a"), delete_line("")}},
+		{name: "trailing newline kept", from: "a\n", to: "b\n", want: []Line{delete_line("a"), insert_line("b"), equal_line("")}},
+		{name: "windows line endings", from: "a\r\nb", to: "a\nb", want: []Line{equal_line("a"), equal_line("b")}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Lines(test.from, test.to)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Lines(%q, %q) = %v, want %v", test.from, test.to, got, test.want)
+			}
+			from, to := sides(got)
+			want_from := strings.ReplaceAll(test.from, "\r\n", "\n")
+			want_to := strings.ReplaceAll(test.to, "\r\n", "\n")
+			if from != want_from || to != want_to {
+				t.Errorf("diff rebuilds %q and %q, want %q and %q", from, to, want_from, want_to)
+			}
+		})
+	}
+}
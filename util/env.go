@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"go-graphql-api/util/logger"
 	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -41,6 +43,18 @@ func EnvOrDefault(envkey string, default_value string) string {
 	return value
 }
 
+// Same as `EnvOrDefault`, but the value is parsed as an integer.
+// If the variable is not a valid integer, the `default_value` is used.
+func EnvIntOrDefault(envkey string, default_value int) int {
+	value := EnvOrDefault(envkey, strconv.Itoa(default_value))
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Err("Env variable \"%s\" is not an integer, using default value: %d", envkey, default_value)
+		return default_value
+	}
+	return parsed
+}
+
 func ServerPort() string {
 	return EnvOrDefault("SERVER_PORT", "8080")
 }
@@ -49,3 +63,21 @@ func ServerUri() string {
 	host := EnvOrDefault("SERVER_HOST", "http://localhost")
 	return fmt.Sprintf("%s:%s", host, ServerPort())
 }
+
+// How long soft deleted posts are kept around, and can be restored,
+// before they are purged for good.
+func PostRetentionPeriod() time.Duration {
+	return time.Duration(EnvIntOrDefault("POST_RETENTION_DAYS", 30)) * 24 * time.Hour
+}
+
+// Same as `EnvOrDefault`, but the value is parsed as a boolean.
+// If the variable is not a valid boolean, the `default_value` is used.
+func EnvBoolOrDefault(envkey string, default_value bool) bool {
+	value := EnvOrDefault(envkey, strconv.FormatBool(default_value))
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Err("Env variable \"%s\" is not a boolean, using default value: %t", envkey, default_value)
+		return default_value
+	}
+	return parsed
+}
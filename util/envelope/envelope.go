@@ -0,0 +1,183 @@
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+	"strings"
+)
+
+// Envelope encryption of values stored in the database: every record is
+// encrypted with its own random data key, which is stored next to the
+// record wrapped by a key-encryption key (KEK). Rotating the KEK only
+// needs the data keys to be wrapped again, the KEK used for a record is
+// identified by the key id stored along with it.
+
+const _data_key_size = 32
+
+// Key-encryption keys by id, and the id of the one wrapping new data keys.
+// Empty until `LoadKeys` is called, in which case nothing is encrypted.
+var (
+	_keks          = map[string]cipher.AEAD{}
+	_active_kek_id = ""
+)
+
+// Load the key-encryption keys from TOKEN_ENCRYPTION_KEYS, a comma
+// separated list of `<kid>:<base64 encoded 32 bytes key>`. New data keys
+// are wrapped with the key TOKEN_ENCRYPTION_KEY_ID, which defaults to the
+// first key of the list. The other keys are only used to decrypt.
+func LoadKeys() error {
+	entries := util.EnvOrDefault("TOKEN_ENCRYPTION_KEYS", "")
+	if len(entries) == 0 {
+		logger.Warn("TOKEN_ENCRYPTION_KEYS not defined, provider tokens are stored in plaintext")
+		return nil
+	}
+
+	keks := map[string]cipher.AEAD{}
+	first := ""
+	for _, entry := range strings.Split(entries, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || len(id) == 0 {
+			return fmt.Errorf("invalid entry in TOKEN_ENCRYPTION_KEYS, expected <kid>:<key>")
+		}
+		if _, ok := keks[id]; ok {
+			return fmt.Errorf("duplicate key id %q in TOKEN_ENCRYPTION_KEYS", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("key %q is not valid base64: %v", id, err)
+		}
+		if len(key) != _data_key_size {
+			return fmt.Errorf("key %q must be %d bytes long, got %d", id, _data_key_size, len(key))
+		}
+		aead, err := new_aead(key)
+		if err != nil {
+			return fmt.Errorf("key %q: %v", id, err)
+		}
+		keks[id] = aead
+		if len(first) == 0 {
+			first = id
+		}
+	}
+
+	active := util.EnvOrDefault("TOKEN_ENCRYPTION_KEY_ID", first)
+	if _, ok := keks[active]; !ok {
+		return fmt.Errorf("TOKEN_ENCRYPTION_KEY_ID %q not found in TOKEN_ENCRYPTION_KEYS", active)
+	}
+
+	_keks = keks
+	_active_kek_id = active
+	logger.Info("Loaded %d token encryption key(s), encrypting with %q", len(keks), active)
+	return nil
+}
+
+// Whether new records are encrypted.
+func Enabled() bool {
+	return len(_active_kek_id) > 0
+}
+
+// Id of the key-encryption key wrapping new data keys.
+func ActiveKeyID() string {
+	return _active_kek_id
+}
+
+func new_aead(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Data key of a record, along with its wrapped form to store.
+type DataKey struct {
+	// Id of the key-encryption key that wrapped the data key.
+	KeyID string
+	// The data key encrypted with the key-encryption key, base64 encoded.
+	Wrapped string
+
+	aead cipher.AEAD
+}
+
+// Generate a data key for a new record, wrapped with the active key.
+func NewDataKey() (*DataKey, error) {
+	kek, ok := _keks[_active_kek_id]
+	if !ok {
+		return nil, fmt.Errorf("token encryption is not configured")
+	}
+	key := make([]byte, _data_key_size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %v", err)
+	}
+	aead, err := new_aead(key)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := seal(kek, key, _active_kek_id)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{KeyID: _active_kek_id, Wrapped: wrapped, aead: aead}, nil
+}
+
+// Unwrap the data key of a record, wrapped with the key `key_id`.
+func OpenDataKey(key_id string, wrapped string) (*DataKey, error) {
+	kek, ok := _keks[key_id]
+	if !ok {
+		return nil, fmt.Errorf("unknown token encryption key %q", key_id)
+	}
+	key, err := open(kek, wrapped, key_id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	aead, err := new_aead(key)
+	if err != nil {
+		return nil, err
+	}
+	return &DataKey{KeyID: key_id, Wrapped: wrapped, aead: aead}, nil
+}
+
+// Encrypt `plaintext`. The `context` is authenticated along with it, so
+// the ciphertext can not be passed off as the value of another field.
+func (k *DataKey) Encrypt(plaintext string, context string) (string, error) {
+	return seal(k.aead, []byte(plaintext), context)
+}
+
+// Decrypt a value encrypted by `Encrypt` with the same `context`.
+func (k *DataKey) Decrypt(ciphertext string, context string) (string, error) {
+	plaintext, err := open(k.aead, ciphertext, context)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Encrypt `plaintext` into base64(nonce || ciphertext).
+func seal(aead cipher.AEAD, plaintext []byte, context string) (string, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(context))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func open(aead cipher.AEAD, encoded string, context string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed ciphertext: %v", err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, fmt.Errorf("malformed ciphertext")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(context))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %v", err)
+	}
+	return plaintext, nil
+}
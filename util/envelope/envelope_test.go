@@ -0,0 +1,138 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/base64"
+	"testing"
+)
+
+// Base64 encoded key-encryption key made of `b` repeated.
+func test_key(b byte, size int) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, size))
+}
+
+// Load `keys` as the key-encryption keys, restoring the previous ones once
+// the test is done.
+func load_test_keys(t *testing.T, keys string, active string) error {
+	keks, active_kek_id := _keks, _active_kek_id
+	t.Cleanup(func() {
+		_keks, _active_kek_id = keks, active_kek_id
+	})
+	_keks, _active_kek_id = map[string]cipher.AEAD{}, ""
+
+	t.Setenv("TOKEN_ENCRYPTION_KEYS", keys)
+	t.Setenv("TOKEN_ENCRYPTION_KEY_ID", active)
+	return LoadKeys()
+}
+
+func TestLoadKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		keys        string
+		active      string
+		want_err    bool
+		want_active string
+		want_count  int
+	}{
+		{name: "not configured", keys: ""},
+		{name: "single key", keys: "k1:" + test_key(1, 32), want_active: "k1", want_count: 1},
+		{name: "first key is active", keys: "k1:" + test_key(1, 32) + ",k2:" + test_key(2, 32), want_active: "k1", want_count: 2},
+		{name: "spaces around entries", keys: " k1:" + test_key(1, 32) + " , k2:" + test_key(2, 32), want_active: "k1", want_count: 2},
+		{name: "explicit active key", keys: "k1:" + test_key(1, 32) + ",k2:" + test_key(2, 32), active: "k2", want_active: "k2", want_count: 2},
+		{name: "missing separator", keys: "k1" + test_key(1, 32), want_err: true},
+		{name: "missing key id", keys: ":" + test_key(1, 32), want_err: true},
+		{name: "duplicate key id", keys: "k1:" + test_key(1, 32) + ",k1:" + test_key(2, 32), want_err: true},
+		{name: "invalid base64", keys: "k1:not base64!", want_err: true},
+		{name: "short key", keys: "k1:" + test_key(1, 16), want_err: true},
+		{name: "unknown active key", keys: "k1:" + test_key(1, 32), active: "k2", want_err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := load_test_keys(t, test.keys, test.active)
+			if (err != nil) != test.want_err {
+				t.Fatalf("LoadKeys() error = %v, want error %v", err, test.want_err)
+			}
+			if ActiveKeyID() != test.want_active {
+				t.Errorf("ActiveKeyID() = %q, want %q", ActiveKeyID(), test.want_active)
+			}
+			if Enabled() != (len(test.want_active) > 0) {
+				t.Errorf("Enabled() = %v, want %v", Enabled(), len(test.want_active) > 0)
+			}
+			if len(_keks) != test.want_count {
+				t.Errorf("loaded %d keys, want %d", len(_keks), test.want_count)
+			}
+		})
+	}
+}
+
+func TestDataKey(t *testing.T) {
+	const plaintext = "provider-access-token"
+	const context = "access_token:42:google:2"
+
+	tests := []struct {
+		name string
+		// Keys used to open the data key and decrypt, after encrypting
+		// with the key "k1" made of ones.
+		open_keys    string
+		open_context string
+		want_err     bool
+	}{
+		{name: "round trip", open_keys: "k1:" + test_key(1, 32), open_context: context},
+		{name: "rotated active key", open_keys: "k2:" + test_key(2, 32) + ",k1:" + test_key(1, 32), open_context: context},
+		{name: "wrong context", open_keys: "k1:" + test_key(1, 32), open_context: "access_token:43:google:2", want_err: true},
+		{name: "other field", open_keys: "k1:" + test_key(1, 32), open_context: "refresh_token:42:google:2", want_err: true},
+		{name: "wrong key-encryption key", open_keys: "k1:" + test_key(3, 32), open_context: context, want_err: true},
+		{name: "unknown key-encryption key", open_keys: "k2:" + test_key(1, 32), open_context: context, want_err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := load_test_keys(t, "k1:"+test_key(1, 32), ""); err != nil {
+				t.Fatalf("LoadKeys() error = %v", err)
+			}
+			key, err := NewDataKey()
+			if err != nil {
+				t.Fatalf("NewDataKey() error = %v", err)
+			}
+			ciphertext, err := key.Encrypt(plaintext, context)
+			if err != nil {
+				t.Fatalf("Encrypt() error = %v", err)
+			}
+			if ciphertext == plaintext {
+				t.Fatalf("Encrypt() returned the plaintext")
+			}
+
+			if err := load_test_keys(t, test.open_keys, ""); err != nil {
+				t.Fatalf("LoadKeys() error = %v", err)
+			}
+			opened, err := OpenDataKey(key.KeyID, key.Wrapped)
+			if err == nil {
+				var decrypted string
+				decrypted, err = opened.Decrypt(ciphertext, test.open_context)
+				if err == nil && decrypted != plaintext {
+					t.Fatalf("Decrypt() = %q, want %q", decrypted, plaintext)
+				}
+			}
+			if (err != nil) != test.want_err {
+				t.Errorf("error = %v, want error %v", err, test.want_err)
+			}
+		})
+	}
+}
+
+func TestDecryptMalformedCiphertext(t *testing.T) {
+	if err := load_test_keys(t, "k1:"+test_key(1, 32), ""); err != nil {
+		t.Fatalf("LoadKeys() error = %v", err)
+	}
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatalf("NewDataKey() error = %v", err)
+	}
+	for _, ciphertext := range []string{"", "not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := key.Decrypt(ciphertext, "access_token"); err == nil {
+			t.Errorf("Decrypt(%q) succeeded, want error", ciphertext)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package gql_cache
+
+import (
+	"context"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/jinzhu/gorm"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const (
+	Store_Memory = "memory"
+	Store_MySQL  = "mysql"
+)
+
+// In-memory LRU cache holding at most `size` entries.
+func NewMemory(size int) graphql.Cache {
+	return lru.New(size)
+}
+
+// Cache the persisted queries according to the `APQ_STORE` env
+// variable. The MySQL store is fronted by an in-memory LRU so hot
+// queries do not hit the database.
+func PersistedQueryCache(db *gorm.DB) graphql.Cache {
+	memory := NewMemory(util.EnvIntOrDefault("APQ_MEMORY_CACHE_SIZE", 1000))
+
+	store := util.EnvOrDefault("APQ_STORE", Store_MySQL)
+	switch store {
+	case Store_Memory:
+		return memory
+	case Store_MySQL:
+		return NewTiered(memory, NewMySQL(db))
+	default:
+		logger.Warn("unknown APQ_STORE \"%s\", persisted queries are only kept in memory", store)
+		return memory
+	}
+}
+
+// Chain of caches, looked up in order. A value found in a later cache
+// is added to the earlier ones, and new values are added to all of them.
+// Register it as an extension of the server too, for the caches that
+// are extensions themselves, like `MySQL`.
+type Tiered struct {
+	caches []graphql.Cache
+}
+
+var (
+	_ graphql.Cache                   = &Tiered{}
+	_ graphql.HandlerExtension        = &Tiered{}
+	_ graphql.OperationContextMutator = &Tiered{}
+)
+
+func NewTiered(caches ...graphql.Cache) *Tiered {
+	return &Tiered{caches: caches}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string) (interface{}, bool) {
+	for i, cache := range t.caches {
+		value, ok := cache.Get(ctx, key)
+		if !ok {
+			continue
+		}
+		for _, previous := range t.caches[:i] {
+			previous.Add(ctx, key, value)
+		}
+		return value, true
+	}
+	return nil, false
+}
+
+func (t *Tiered) Add(ctx context.Context, key string, value interface{}) {
+	for _, cache := range t.caches {
+		cache.Add(ctx, key, value)
+	}
+}
+
+func (t *Tiered) ExtensionName() string {
+	return "TieredCache"
+}
+
+func (t *Tiered) Validate(schema graphql.ExecutableSchema) error {
+	for _, cache := range t.caches {
+		if extension, ok := cache.(graphql.HandlerExtension); ok {
+			if err := extension.Validate(schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *Tiered) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	for _, cache := range t.caches {
+		if mutator, ok := cache.(graphql.OperationContextMutator); ok {
+			if err := mutator.MutateOperationContext(ctx, rc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package gql_cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"go-graphql-api/util/logger"
+
+	"github.com/99designs/gqlgen/graphql"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/jinzhu/gorm"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Persisted query cache backed by the `persisted_queries` table, so the
+// registered hashes survive restarts and are shared between replicas.
+// Only string values, the query documents, can be stored.
+//
+// The APQ extension adds the documents before they are even parsed, so
+// they are only kept in memory at first. They are written to the
+// database once an operation using them is valid, which requires the
+// cache to be registered as an extension of the server as well.
+type MySQL struct {
+	db *gorm.DB
+	// Documents added but not validated yet, by hash.
+	pending *lru.Cache[string, string]
+	// Documents longer than this, in bytes, are not persisted.
+	max_size int
+}
+
+var (
+	_ graphql.Cache                   = &MySQL{}
+	_ graphql.HandlerExtension        = &MySQL{}
+	_ graphql.OperationContextMutator = &MySQL{}
+)
+
+func NewMySQL(db *gorm.DB) *MySQL {
+	pending, err := lru.New[string, string](util.EnvIntOrDefault("APQ_MEMORY_CACHE_SIZE", 1000))
+	if err != nil {
+		panic(err)
+	}
+	return &MySQL{
+		db:       db,
+		pending:  pending,
+		max_size: util.EnvIntOrDefault("APQ_MAX_QUERY_BYTES", 16384),
+	}
+}
+
+func (c *MySQL) Get(ctx context.Context, key string) (interface{}, bool) {
+	var query dbmodel.PersistedQuery
+	err := c.db.Where("hash = ?", key).First(&query).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, false
+	}
+	if err != nil {
+		logger.Err("failed to fetch persisted query %s: %v", key, err)
+		return nil, false
+	}
+
+	// Only touched once an hour, to keep reads from writing every time.
+	err = c.db.Exec(
+		"UPDATE persisted_queries SET last_used_at = NOW() "+
+			"WHERE hash = ? AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL 1 HOUR)",
+		key).Error
+	if err != nil {
+		logger.Err("failed to touch persisted query %s: %v", key, err)
+	}
+	return query.Query, true
+}
+
+func (c *MySQL) Add(ctx context.Context, key string, value interface{}) {
+	document, ok := value.(string)
+	if !ok {
+		logger.Warn("not persisting query %s: unsupported value type %T", key, value)
+		return
+	}
+	if len(document) > c.max_size {
+		logger.Warn("not persisting query %s: %d bytes is over APQ_MAX_QUERY_BYTES", key, len(document))
+		return
+	}
+	c.pending.Add(key, document)
+}
+
+func (c *MySQL) ExtensionName() string {
+	return "MySQLPersistedQueries"
+}
+
+func (c *MySQL) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+// Persist the pending document of `rc`, which is only called once the
+// operation was parsed and validated.
+func (c *MySQL) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	sum := sha256.Sum256([]byte(rc.RawQuery))
+	key := hex.EncodeToString(sum[:])
+	document, ok := c.pending.Get(key)
+	if !ok {
+		return nil
+	}
+	c.pending.Remove(key)
+
+	// Replicas may register the same hash concurrently, the document is
+	// the same for a given hash so the first one wins.
+	err := c.db.Exec(
+		"INSERT IGNORE INTO persisted_queries (hash, query, created_at, last_used_at) VALUES (?, ?, NOW(), NOW())",
+		key, document).Error
+	if err != nil {
+		logger.Err("failed to store persisted query %s: %v", key, err)
+	}
+	return nil
+}
@@ -2,14 +2,20 @@ package gql_middleware
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go-graphql-api/auth"
 	"go-graphql-api/database"
 	"go-graphql-api/dbmodel"
 	"go-graphql-api/util"
 	"go-graphql-api/util/logger"
 	"net/http"
+	"strings"
 
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/golang-jwt/jwt"
+	"github.com/jinzhu/gorm"
 )
 
 // Auth example modified from: https://gqlgen.com/recipes/authentication/
@@ -21,64 +27,163 @@ import (
 // The auth token is expected to be in the request header in this format:
 //
 //	Authorization: Bearer <jwt token>
+//
+// or in the session cookie set at the end of the OAuth login.
+//
+// Requests without a token are served anonymously, while requests with
+// an invalid token are rejected with a 401 status and a
+// `WWW-Authenticate` header, so clients know to get a new token.
+// Requests with an expired token are served anonymously along with the
+// header, so clients can refresh their session without dropping it.
 func JwtAuthMiddleware() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			updated_request, err := ProcessAuthFromRequestHeader(r)
+			if errors.Is(err, auth.ErrExpiredToken) {
+				// Served anonymously, so clients can still refresh their
+				// session with the `refreshToken` mutation while sending
+				// their expired token. The header tells them it expired.
+				w.Header().Set("WWW-Authenticate", www_authenticate(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+			if errors.Is(err, auth.ErrInvalidToken) {
+				logger.Warn("Rejected request with an invalid auth token: %v", err)
+				reject_invalid_token(w, err)
+				return
+			}
 			if err != nil {
-				logger.Err("Error processing auth from request header: %#v", err)
+				logger.Err("Error processing auth from request header: %v", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
 			}
 			next.ServeHTTP(w, updated_request)
 		})
 	}
 }
 
+// Respond with a 401 status as described in RFC 6750, with the body of
+// a GraphQL error. The details of `err` are only logged, the client is
+// only told whether the token expired.
+func reject_invalid_token(w http.ResponseWriter, err error) {
+	description := invalid_token_description(err)
+	w.Header().Set("WWW-Authenticate", www_authenticate(err))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    description,
+			"extensions": map[string]interface{}{"code": "UNAUTHENTICATED"},
+		}},
+	})
+}
+
+func invalid_token_description(err error) string {
+	if errors.Is(err, auth.ErrExpiredToken) {
+		return "The access token expired"
+	}
+	return "The access token is invalid"
+}
+
+// `WWW-Authenticate` challenge for a request with an invalid token.
+func www_authenticate(err error) string {
+	return `Bearer error="invalid_token", error_description=` + quoted_string(invalid_token_description(err))
+}
+
+// Format `s` as an RFC 7230 quoted-string.
+func quoted_string(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
 func ProcessAuthFromRequestHeader(r *http.Request) (*http.Request, error) {
-	auth_bearer := r.Header.Get("Authorization")
-	if len(auth_bearer) <= 7 || auth_bearer[:7] != "Bearer " {
-		// No auth header, not an error, just continue normal request
+	user, claims, err := UserFromAuthorization(r.Header.Get("Authorization"))
+	if err == nil && user == nil {
+		// Browsers signed in through OAuth send the token as a cookie.
+		user, claims, err = user_from_access_token(auth.SessionCookieToken(r))
+	}
+	if err != nil {
+		return r, err
+	}
+	if user == nil {
+		// No auth token, not an error, just continue normal request
 		logger.Info("Serving request without auth token.")
 		return r, nil
 	}
+	// Successfully parsed the user payload, store it in the request's context.
+	return r.WithContext(with_auth(r.Context(), user, claims)), nil
+}
 
-	tokenstr := auth_bearer[7:]
-	logger.Info("Attempting to validate auth token: %s", tokenstr)
-	token, err := jwt.Parse(tokenstr, func(t *jwt.Token) (interface{}, error) {
-		// Must validate that the token is using the expected algo.
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
-		}
-		secret := util.EnvOrDefault("JWT_SECRET", "")
-		if len(secret) == 0 {
-			return nil, fmt.Errorf("No jwt secret found.")
-		}
-		return []byte(secret), nil
-	})
+// Authenticate a websocket connection (used by subscriptions) from the
+// `Authorization` entry of its `connection_init` payload, which accepts
+// the same `Bearer <jwt token>` value as the request header. Connections
+// with an invalid token are rejected.
+func WebsocketAuthInit(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+	user, claims, err := UserFromAuthorization(payload.Authorization())
 	if err != nil {
-		return r, err
+		logger.Err("Error processing auth from websocket init payload: %v", err)
+		return ctx, nil, fmt.Errorf("invalid auth token")
 	}
-	// Parse the token into a user payload
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return r, fmt.Errorf("Failed to get claims from jwt auth token")
+	if user == nil {
+		return ctx, nil, nil
+	}
+	return with_auth(ctx, user, claims), nil, nil
+}
+
+// Resolve the user, and the claims of their token, from an authorization
+// value of the form `Bearer <jwt token>`. The user is nil when there is
+// no bearer token.
+func UserFromAuthorization(auth_bearer string) (*dbmodel.User, jwt.MapClaims, error) {
+	if len(auth_bearer) <= 7 || auth_bearer[:7] != "Bearer " {
+		return nil, nil, nil
+	}
+
+	return user_from_access_token(auth_bearer[7:])
+}
+
+// Resolve the user identified by a JWT access token. The user is nil
+// when `tokenstr` is empty.
+func user_from_access_token(tokenstr string) (*dbmodel.User, jwt.MapClaims, error) {
+	if len(tokenstr) == 0 {
+		return nil, nil, nil
+	}
+
+	claims, err := auth.ParseAccessToken(tokenstr)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	user, err := UserFromToken(&claims)
 	if err != nil {
-		return r, err
+		return nil, nil, err
 	}
-	// Successfully parsed the user payload, store it in the request's context.
 	logger.Info("User auth token translated to a valid user payload.")
-	ctx := context.WithValue(r.Context(), util.ContextKey_User, user)
-	return r.WithContext(ctx), nil
+	return user, claims, nil
+}
 
+func with_auth(ctx context.Context, user *dbmodel.User, claims jwt.MapClaims) context.Context {
+	ctx = context.WithValue(ctx, util.ContextKey_User, user)
+	return context.WithValue(ctx, util.ContextKey_AuthClaims, claims)
+}
+
+// Get the user attached to the request context by `JwtAuthMiddleware`.
+// Returns nil for anonymous requests.
+func UserFromContext(ctx context.Context) *dbmodel.User {
+	user, _ := ctx.Value(util.ContextKey_User).(*dbmodel.User)
+	return user
+}
+
+// Get the claims of the access token the user of the request was
+// authenticated with. Returns nil for anonymous requests.
+func ClaimsFromContext(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(util.ContextKey_AuthClaims).(jwt.MapClaims)
+	return claims
 }
 
 func UserFromToken(claims *jwt.MapClaims) (*dbmodel.User, error) {
-	id_opaq := (*claims)["id"]
+	id_opaq := (*claims)[auth.Claim_UserID]
 	id, ok := id_opaq.(float64)
 	if !ok {
-		return nil, fmt.Errorf("invalid id type in payload")
+		return nil, fmt.Errorf("%w: invalid id type in payload", auth.ErrInvalidToken)
 	}
 
 	db, err := database.GetDbInstance()
@@ -87,10 +192,15 @@ func UserFromToken(claims *jwt.MapClaims) (*dbmodel.User, error) {
 	}
 
 	var user dbmodel.User
-	db.Find(&user, []int{int(id)})
-
+	err = db.Where("id = ?", uint64(id)).First(&user).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, fmt.Errorf("%w: no user found with id %d", auth.ErrInvalidToken, int(id))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user %d: %v", int(id), err)
+	}
 	if float64(user.ID) != id {
-		return nil, fmt.Errorf("no user found with id %f", id)
+		return nil, fmt.Errorf("%w: no user found with id %d", auth.ErrInvalidToken, int(id))
 	}
 	return &user, nil
 }
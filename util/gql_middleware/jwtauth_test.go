@@ -0,0 +1,101 @@
+package gql_middleware
+
+import (
+	"go-graphql-api/auth"
+	"go-graphql-api/dbmodel"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func issue_test_token(t *testing.T, ttl_minutes string) string {
+	t.Setenv("JWT_SECRET", "test-secret")
+	t.Setenv("JWT_ACCESS_TOKEN_TTL_MINUTES", ttl_minutes)
+	token, _, err := auth.IssueAccessToken(&dbmodel.User{ID: 42}, "session")
+	if err != nil {
+		t.Fatalf("failed to issue token: %v", err)
+	}
+	return token
+}
+
+// Clients refresh their session while still sending their expired access
+// token: the request must reach the `refreshToken` mutation anonymously.
+func TestJwtAuthMiddlewareExpiredToken(t *testing.T) {
+	expired := issue_test_token(t, "-5")
+
+	tests := []struct {
+		name          string
+		authorization string
+		want_status   int
+		want_served   bool
+		want_header   string
+	}{
+		{
+			name:        "no token",
+			want_status: http.StatusOK,
+			want_served: true,
+		},
+		{
+			name:          "expired token",
+			authorization: "Bearer " + expired,
+			want_status:   http.StatusOK,
+			want_served:   true,
+			want_header:   `Bearer error="invalid_token", error_description="The access token expired"`,
+		},
+		{
+			name:          "malformed token",
+			authorization: "Bearer not-a-token",
+			want_status:   http.StatusUnauthorized,
+			want_served:   false,
+			want_header:   `Bearer error="invalid_token", error_description="The access token is invalid"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			served := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served = true
+				if user := UserFromContext(r.Context()); user != nil {
+					t.Errorf("request served as user %d, want anonymous", user.ID)
+				}
+			})
+
+			body := `{"query":"mutation { refreshToken(token: \"refresh\") { accessToken } }"}`
+			r := httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body))
+			if len(test.authorization) > 0 {
+				r.Header.Set("Authorization", test.authorization)
+			}
+			w := httptest.NewRecorder()
+			JwtAuthMiddleware()(next).ServeHTTP(w, r)
+
+			if w.Code != test.want_status {
+				t.Errorf("status = %d, want %d", w.Code, test.want_status)
+			}
+			if served != test.want_served {
+				t.Errorf("served = %v, want %v", served, test.want_served)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != test.want_header {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, test.want_header)
+			}
+		})
+	}
+}
+
+func TestQuotedString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "plain", want: `"plain"`},
+		{in: `say "hi"`, want: `"say \"hi\""`},
+		{in: `back\slash`, want: `"back\\slash"`},
+		{in: "it's", want: `"it's"`},
+	}
+	for _, test := range tests {
+		if got := quoted_string(test.in); got != test.want {
+			t.Errorf("quoted_string(%q) = %s, want %s", test.in, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gql_middleware
+
+import (
+	"context"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const ErrCode_DepthLimitExceeded = "DEPTH_LIMIT_EXCEEDED"
+
+// Budget of a single GraphQL operation.
+type QueryLimits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+var (
+	_user_query_limits = QueryLimits{
+		MaxDepth:      util.EnvIntOrDefault("GQL_MAX_DEPTH", 12),
+		MaxComplexity: util.EnvIntOrDefault("GQL_MAX_COMPLEXITY", 10000),
+	}
+	_admin_query_limits = QueryLimits{
+		MaxDepth:      util.EnvIntOrDefault("GQL_ADMIN_MAX_DEPTH", 20),
+		MaxComplexity: util.EnvIntOrDefault("GQL_ADMIN_MAX_COMPLEXITY", 100000),
+	}
+)
+
+// Get the budget of the operations sent by the user of `ctx`. Admins get
+// a bigger budget than everyone else.
+func QueryLimitsFor(ctx context.Context) QueryLimits {
+	user := UserFromContext(ctx)
+	if user != nil && user.Type == dbmodel.UserType_Admin {
+		return _admin_query_limits
+	}
+	return _user_query_limits
+}
+
+// Reject operations whose complexity, as computed from the
+// `ComplexityRoot` of the schema, exceeds the budget of the user. The
+// error code of rejected operations is COMPLEXITY_LIMIT_EXCEEDED.
+func ComplexityLimit() *extension.ComplexityLimit {
+	return &extension.ComplexityLimit{
+		Func: func(ctx context.Context, rc *graphql.OperationContext) int {
+			return QueryLimitsFor(ctx).MaxComplexity
+		},
+	}
+}
+
+// Reject operations nesting fields deeper than the budget of the user,
+// with the DEPTH_LIMIT_EXCEEDED error code. Introspection fields are not
+// counted so tools can still load the schema.
+type DepthLimit struct{}
+
+var _ interface {
+	graphql.OperationContextMutator
+	graphql.HandlerExtension
+} = DepthLimit{}
+
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (DepthLimit) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (DepthLimit) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		return nil
+	}
+
+	depth := selection_depth(op.SelectionSet)
+	limit := QueryLimitsFor(ctx).MaxDepth
+	if depth > limit {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, limit)
+		errcode.Set(err, ErrCode_DepthLimitExceeded)
+		return err
+	}
+	return nil
+}
+
+func selection_depth(selections ast.SelectionSet) int {
+	deepest := 0
+	for _, selection := range selections {
+		depth := 0
+		switch s := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			depth = 1 + selection_depth(s.SelectionSet)
+		case *ast.InlineFragment:
+			depth = selection_depth(s.SelectionSet)
+		case *ast.FragmentSpread:
+			// Fragment cycles are rejected by validation before this runs.
+			if s.Definition != nil {
+				depth = selection_depth(s.Definition.SelectionSet)
+			}
+		}
+		if depth > deepest {
+			deepest = depth
+		}
+	}
+	return deepest
+}
@@ -0,0 +1,135 @@
+package gql_middleware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-graphql-api/dbmodel"
+	"go-graphql-api/util/logger"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+const ErrCode_UntrustedDocument = "UNTRUSTED_DOCUMENT"
+
+// Only execute the operations listed in a manifest of trusted documents,
+// a JSON object mapping operation ids to their document. Clients send
+// the id of the operation the same way as an automatic persisted query,
+// in `extensions.persistedQuery.sha256Hash`, without the document.
+//
+// Admins may still send ad-hoc documents, everyone else gets an
+// UNTRUSTED_DOCUMENT error. Register it before the automatic persisted
+// query extension so trusted ids never reach the persisted query cache.
+type TrustedDocuments struct {
+	path string
+
+	mu        sync.RWMutex
+	documents map[string]string
+	mod_time  time.Time
+}
+
+var _ interface {
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = &TrustedDocuments{}
+
+// Load the manifest of trusted documents found at `path`.
+func NewTrustedDocuments(path string) (*TrustedDocuments, error) {
+	td := &TrustedDocuments{path: path}
+	if err := td.Reload(); err != nil {
+		return nil, err
+	}
+	return td, nil
+}
+
+// Load the manifest again if the file changed since it was last loaded.
+// The previous manifest is kept if the new one can not be read.
+func (td *TrustedDocuments) Reload() error {
+	info, err := os.Stat(td.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat trusted documents manifest: %v", err)
+	}
+
+	td.mu.RLock()
+	unchanged := info.ModTime().Equal(td.mod_time)
+	td.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	content, err := os.ReadFile(td.path)
+	if err != nil {
+		return fmt.Errorf("failed to read trusted documents manifest: %v", err)
+	}
+	var documents map[string]string
+	if err := json.Unmarshal(content, &documents); err != nil {
+		return fmt.Errorf("failed to parse trusted documents manifest: %v", err)
+	}
+
+	td.mu.Lock()
+	td.documents = documents
+	td.mod_time = info.ModTime()
+	td.mu.Unlock()
+
+	logger.Info("Loaded %d trusted documents from %s", len(documents), td.path)
+	return nil
+}
+
+func (td *TrustedDocuments) document(id string) (string, bool) {
+	td.mu.RLock()
+	defer td.mu.RUnlock()
+	document, ok := td.documents[id]
+	return document, ok
+}
+
+func (*TrustedDocuments) ExtensionName() string {
+	return "TrustedDocuments"
+}
+
+func (*TrustedDocuments) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (td *TrustedDocuments) MutateOperationParameters(ctx context.Context, params *graphql.RawParams) *gqlerror.Error {
+	id := operation_id(params.Extensions)
+	if id != "" {
+		if document, ok := td.document(id); ok {
+			params.Query = document
+			delete(params.Extensions, "persistedQuery")
+			return nil
+		}
+	}
+
+	user := UserFromContext(ctx)
+	if user != nil && user.Type == dbmodel.UserType_Admin {
+		return nil
+	}
+
+	user_id := "anonymous"
+	if user != nil {
+		user_id = fmt.Sprint(user.ID)
+	}
+	if id != "" {
+		logger.Warn("Rejected unknown operation id %q (operation: %q, user: %s)", id, params.OperationName, user_id)
+	} else {
+		logger.Warn("Rejected ad-hoc document (operation: %q, user: %s)", params.OperationName, user_id)
+	}
+
+	err := gqlerror.Errorf("only trusted documents can be executed")
+	errcode.Set(err, ErrCode_UntrustedDocument)
+	return err
+}
+
+func operation_id(extensions map[string]interface{}) string {
+	persisted_query, ok := extensions["persistedQuery"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	id, _ := persisted_query["sha256Hash"].(string)
+	return id
+}
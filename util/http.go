@@ -0,0 +1,22 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Send `json_data` as the JSON body of the response. The responses carry
+// tokens or errors specific to the request, so they are never cached.
+func WriteJSON(w http.ResponseWriter, statuscode int, json_data map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statuscode)
+	json.NewEncoder(w).Encode(json_data)
+}
+
+// Number of seconds left until `t`, rounded to the nearest second, as
+// used by the `expires_in` of token responses.
+func SecondsUntil(t time.Time) int {
+	return int(time.Until(t).Round(time.Second).Seconds())
+}
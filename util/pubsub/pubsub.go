@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"context"
+	"go-graphql-api/util/logger"
+	"sync"
+)
+
+// Size of the message buffer of every subscription.
+const _subscription_buffer = 16
+
+// In-process publish/subscribe broker. Subscribers receive the messages
+// published to their topic for as long as their context is alive.
+//
+// Publishing never blocks: a subscriber that can not keep up with its
+// topic misses the messages that do not fit in its buffer.
+type Broker[T any] struct {
+	mu     sync.RWMutex
+	topics map[string]map[chan T]struct{}
+}
+
+func NewBroker[T any]() *Broker[T] {
+	return &Broker[T]{topics: map[string]map[chan T]struct{}{}}
+}
+
+// Subscribe to the messages published to `topic`. The returned channel
+// is closed once `ctx` is done.
+func (b *Broker[T]) Subscribe(ctx context.Context, topic string) <-chan T {
+	ch := make(chan T, _subscription_buffer)
+
+	b.mu.Lock()
+	if _, ok := b.topics[topic]; !ok {
+		b.topics[topic] = map[chan T]struct{}{}
+	}
+	b.topics[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subscribers := b.topics[topic]
+		delete(subscribers, ch)
+		if len(subscribers) == 0 {
+			delete(b.topics, topic)
+		}
+		close(ch)
+	}()
+	return ch
+}
+
+// Send `message` to every current subscriber of `topic`.
+func (b *Broker[T]) Publish(topic string, message T) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.topics[topic] {
+		select {
+		case ch <- message:
+		default:
+			logger.Warn("Dropping message for slow subscriber of topic [%s]", topic)
+		}
+	}
+}